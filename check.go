@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/iohandler"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/vault"
+	"github.com/urfave/cli/v2"
+)
+
+// checkInventory compares inv, rendered in format, with the inventory in
+// path. Both are read back with the same reader, so only differences that
+// survive the format count; formatting, ordering and comments do not. With
+// enc set, vault-encrypted values of both are compared by their plaintext,
+// as every encryption uses a fresh salt. The diff is printed with sensitive
+// and decrypted values masked and the exit status is 1 when they differ.
+func checkInventory(inv *inventory.Inventory, format, path string, enc *vault.Encrypter) error {
+	var buf bytes.Buffer
+	if err := iohandler.OutputInventory(&buf, inv, format); err != nil {
+		return err
+	}
+	generated, err := iohandler.ReadInventory(&buf, format)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read inventory to check: %w", err)
+	}
+	defer f.Close()
+	existing, err := iohandler.ReadInventory(f, format)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if enc != nil {
+		if err := vault.DecryptInventory(generated, enc.Password); err != nil {
+			return err
+		}
+		if err := vault.DecryptInventory(existing, enc.Password); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	// The formats do not record sensitivity, so carry it over from inv to
+	// both sides before comparing.
+	copySensitive(generated, inv)
+	copySensitive(existing, inv)
+	d := generated.Compare(existing)
+	if d.Empty() {
+		return nil
+	}
+	d.Redact(inventory.MaskSensitive)
+	if err := iohandler.OutputDiff(os.Stdout, d, "text"); err != nil {
+		return err
+	}
+	return cli.Exit(fmt.Sprintf("%s is out of date, regenerate the inventory", path), 1)
+}
+
+// copySensitive marks the variables of dst that src marks as sensitive.
+func copySensitive(dst, src *inventory.Inventory) {
+	dst.Sensitive = inventory.AddSensitive(dst.Sensitive, src.Sensitive)
+	for name, g := range dst.Groups {
+		if sg, ok := src.Groups[name]; ok {
+			g.Sensitive = inventory.AddSensitive(g.Sensitive, sg.Sensitive)
+		}
+	}
+	for name, h := range dst.Hosts {
+		if sh, ok := src.Hosts[name]; ok {
+			h.Sensitive = inventory.AddSensitive(h.Sensitive, sh.Sensitive)
+		}
+	}
+}
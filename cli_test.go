@@ -2,15 +2,27 @@ package main
 
 import (
 	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/vault"
 )
 
 func runCLI(t *testing.T, stdin string, args ...string) (string, error) {
-	cmdArgs := append([]string{"run", "main.go"}, args...)
+	return runCLIEnv(t, nil, stdin, args...)
+}
+
+func runCLIEnv(t *testing.T, env []string, stdin string, args ...string) (string, error) {
+	cmdArgs := append([]string{"run", "."}, args...)
 	cmd := exec.Command("go", cmdArgs...)
+	cmd.Env = append(os.Environ(), env...)
 	if stdin != "" {
 		cmd.Stdin = strings.NewReader(stdin)
 	}
@@ -26,7 +38,7 @@ func TestCLIMissingInput(t *testing.T) {
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
-	if !strings.Contains(out, "Required flag \"input\" not set") {
+	if !strings.Contains(out, "no input given") {
 		t.Fatalf("unexpected output: %s", out)
 	}
 }
@@ -54,3 +66,556 @@ func TestCLIReadStdin(t *testing.T) {
 		t.Fatalf("unexpected output: %s", out)
 	}
 }
+
+func TestCLIHTTPHeaderWithComma(t *testing.T) {
+	data, err := os.ReadFile("smoketest.json")
+	if err != nil {
+		t.Fatalf("read smoketest: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json, text/plain" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(data))
+	}))
+	defer srv.Close()
+
+	out, err := runCLI(t, "", "-i", srv.URL, "--http-header", "Accept: application/json, text/plain", "-f", "ini")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "[web]") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCLIDynamicList(t *testing.T) {
+	out, err := runCLIEnv(t, []string{"TF_ANSIBLE_INVENTORY_STATE=smoketest.json"}, "", "--list")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, `"_meta"`) || !strings.Contains(out, `"ansible_host": "192.168.1.10"`) {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCLIDynamicHost(t *testing.T) {
+	cfg := filepath.Join(t.TempDir(), "cfg.yml")
+	abs, _ := filepath.Abs("smoketest.json")
+	if err := os.WriteFile(cfg, []byte("input: "+abs+"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	out, err := runCLIEnv(t, []string{"TF_ANSIBLE_INVENTORY_CONFIG=" + cfg}, "", "--host", "test1")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "[web]") || !strings.Contains(out, `"os": "linux"`) {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCLIHostFilterWithConfig(t *testing.T) {
+	cfg := filepath.Join(t.TempDir(), "cfg.yml")
+	abs, _ := filepath.Abs("smoketest.json")
+	if err := os.WriteFile(cfg, []byte("input: "+abs+"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, args := range [][]string{
+		{"--config", cfg, "--host", "test1"},
+		{"--config", cfg, "--sensitive", "keep", "--host", "test1"},
+	} {
+		out, err := runCLI(t, "", args...)
+		if err != nil {
+			t.Fatalf("%v: cli run err: %v\n%s", args, err, out)
+		}
+		if !strings.Contains(out, "all:\n") || !strings.Contains(out, "test1:") {
+			t.Fatalf("%v: expected filtered yaml inventory, got: %s", args, out)
+		}
+	}
+}
+
+func TestCLITerraformMetadata(t *testing.T) {
+	out, err := runCLI(t, "", "-i", "smoketest.json", "-f", "ini", "--tf-metadata")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "tf_source=smoketest.json") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCLIValidate(t *testing.T) {
+	out, err := runCLI(t, "", "validate", "-i", "smoketest.json")
+	if err != nil || !strings.Contains(out, "inventory is valid") {
+		t.Fatalf("expected valid inventory: %v\n%s", err, out)
+	}
+
+	state := filepath.Join(t.TempDir(), "state.json")
+	data := `{"type":"ansible_host","values":{"name":"bad host","groups":["web-servers"]}}`
+	if err := os.WriteFile(state, []byte(data), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	out, err = runCLI(t, "", "validate", "-i", state, "-f", "json")
+	if err == nil {
+		t.Fatalf("expected non-zero exit:\n%s", out)
+	}
+	if !strings.Contains(out, `"code": "invalid-host-name"`) || !strings.Contains(out, `"valid": false`) {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCLILimit(t *testing.T) {
+	out, err := runCLI(t, "", "-i", "smoketest.json", "-f", "ini", "--limit", "web:&test*")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "[web]\ntest1") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	out, err = runCLI(t, "", "-i", "smoketest.json", "-f", "ini", "-l", "!web")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "test1") {
+		t.Fatalf("host not excluded: %s", out)
+	}
+	if _, err := runCLI(t, "", "-i", "smoketest.json", "--limit", "~web("); err == nil {
+		t.Fatalf("expected error for invalid pattern")
+	}
+}
+
+func TestCLIWhere(t *testing.T) {
+	out, err := runCLI(t, "", "-i", "smoketest.json", "-f", "ini", "--where", `os == "linux" && tier in ["frontend", "backend"]`)
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "test1") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	out, err = runCLI(t, "", "-i", "smoketest.json", "-f", "ini", "--where", `os =~ "^win"`)
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "test1") {
+		t.Fatalf("host not filtered: %s", out)
+	}
+	if _, err := runCLI(t, "", "-i", "smoketest.json", "--where", `os ==`); err == nil {
+		t.Fatalf("expected error for invalid expression")
+	}
+}
+
+func TestCLIConstructedGroups(t *testing.T) {
+	cfg := filepath.Join(t.TempDir(), "cfg.yml")
+	data := "keyed_groups:\n  - key: os\n    prefix: os\ngroups:\n  frontend: tier == \"frontend\"\n"
+	if err := os.WriteFile(cfg, []byte(data), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	out, err := runCLI(t, "", "-i", "smoketest.json", "-c", cfg, "-f", "ini")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "[os_linux]\ntest1") || !strings.Contains(out, "[frontend]\ntest1") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCLICompose(t *testing.T) {
+	cfg := filepath.Join(t.TempDir(), "cfg.yml")
+	data := "compose:\n  ansible_user: default(admin, \"debian\")\n  fqdn: format(\"{}.example.com\", upper(inventory_hostname_short))\n  label: os + \"-\" + tier\n"
+	if err := os.WriteFile(cfg, []byte(data), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	out, err := runCLI(t, "", "-i", "smoketest.json", "-c", cfg, "-f", "ini")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "ansible_user=debian") || !strings.Contains(out, "label=linux-frontend") || strings.Contains(out, "fqdn") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCLIAddressMapping(t *testing.T) {
+	cfg := filepath.Join(t.TempDir(), "cfg.yml")
+	data := "address:\n  sources: ip\n  target: ansible_ssh_host\n  keep_sources: true\n"
+	if err := os.WriteFile(cfg, []byte(data), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	out, err := runCLI(t, "", "-i", "smoketest.json", "-c", cfg, "-f", "json")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, `"ansible_ssh_host": "192.168.1.10"`) || !strings.Contains(out, `"ip": "192.168.1.10`) || strings.Contains(out, `"ansible_host"`) {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCLIResourceRules(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state.json")
+	data := `{"format_version":"1.0","values":{"root_module":{"resources":[
+		{"address":"hcloud_server.app","type":"hcloud_server","name":"app",
+		 "values":{"name":"app-1","ipv4_address":"203.0.113.7","labels":{"role":"app"}}}]}}}`
+	if err := os.WriteFile(state, []byte(data), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	cfg := filepath.Join(dir, "cfg.yml")
+	rules := "rules:\n  - types: hcloud_server\n    name: values.name\n    vars:\n      ansible_host: values.ipv4_address\n    groups: values.labels.role\n"
+	if err := os.WriteFile(cfg, []byte(rules), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	out, err := runCLI(t, "", "-i", state, "-c", cfg, "-f", "ini")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "[app]\napp-1 ansible_host=203.0.113.7") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCLIPlaybooks(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+	data := `{"format_version":"1.0","values":{"root_module":{"resources":[
+		{"address":"ansible_playbook.site","type":"ansible_playbook","name":"site",
+		 "values":{"playbook":"site.yml","name":"app1","groups":["app"],"replayable":true}}]}}}`
+	if err := os.WriteFile(state, []byte(data), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	out, err := runCLI(t, "", "playbooks", "-i", state)
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if out != "app1\tsite.yml\tgroups=app\treplayable=true\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	out, err = runCLI(t, "", "-i", state, "-f", "ini")
+	if err != nil || !strings.Contains(out, "[app]\napp1") {
+		t.Fatalf("playbook host missing from inventory: %v\n%s", err, out)
+	}
+}
+
+func TestCLIPlaybooksSensitive(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+	data := `{"format_version":"1.0","values":{"root_module":{"resources":[
+		{"address":"ansible_playbook.site","type":"ansible_playbook","name":"site",
+		 "values":{"playbook":"site.yml","name":"app1","extra_vars":{"db_password":"hunter2","env":"prod"}},
+		 "sensitive_values":{"extra_vars":{"db_password":true}}}]}}}`
+	if err := os.WriteFile(state, []byte(data), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	out, err := runCLI(t, "", "playbooks", "-i", state, "-f", "json")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, `"db_password": "***"`) {
+		t.Fatalf("sensitive extra var not masked: %s", out)
+	}
+
+	out, err = runCLIEnv(t, []string{vaultPasswordEnv + "=pw"}, "", "playbooks", "-i", state, "--vault-sensitive")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, "__ansible_vault") {
+		t.Fatalf("sensitive extra var not encrypted: %s", out)
+	}
+}
+
+func TestCLIVault(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state.json")
+	data := `{"format_version":"1.0","values":{"root_module":{"resources":[
+		{"address":"ansible_host.db","type":"ansible_host","name":"db",
+		 "values":{"name":"db1","variables":{"db_password":"hunter2","api_token":"t0k3n","os":"linux"}},
+		 "sensitive_values":{"variables":{"db_password":true}}}]}}}`
+	if err := os.WriteFile(state, []byte(data), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	pwFile := filepath.Join(dir, "vault_pass")
+	if err := os.WriteFile(pwFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("write password: %v", err)
+	}
+	hostVars := filepath.Join(dir, "host_vars")
+
+	out, err := runCLI(t, "", "-i", state, "--vault-sensitive", "--vault-pattern", "token",
+		"--vault-password-file", pwFile, "--host-vars-dir", hostVars)
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "hunter2") || strings.Contains(out, "t0k3n") {
+		t.Fatalf("secrets leaked: %s", out)
+	}
+	if strings.Contains(out, "db_password") || strings.Contains(out, "os: linux") {
+		t.Fatalf("host variables written to --host-vars-dir should not be inlined: %s", out)
+	}
+
+	vt, err := os.ReadFile(filepath.Join(hostVars, "db1", "vault.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := vault.Decrypt(string(vt), []byte("s3cret"))
+	if err != nil || string(pt) != "api_token: t0k3n\ndb_password: hunter2\n" {
+		t.Fatalf("unexpected vault.yml %q (%v)", pt, err)
+	}
+
+	out, err = runCLIEnv(t, []string{vaultPasswordEnv + "="}, "", "-i", state, "--vault-sensitive")
+	if err == nil || !strings.Contains(out, "needs a password") {
+		t.Fatalf("expected missing password error: %v\n%s", err, out)
+	}
+	out, err = runCLIEnv(t, []string{vaultPasswordEnv + "=s3cret"}, "", "-i", state, "--vault-sensitive", "-f", "ini")
+	if err == nil || !strings.Contains(out, "ini output cannot hold") {
+		t.Fatalf("expected ini error: %v\n%s", err, out)
+	}
+
+	iniVars := filepath.Join(dir, "ini_host_vars")
+	out, err = runCLIEnv(t, []string{vaultPasswordEnv + "=s3cret"}, "", "-i", state, "--vault-sensitive", "-f", "ini",
+		"--host-vars-dir", iniVars)
+	if err != nil {
+		t.Fatalf("ini output with --host-vars-dir should work: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "db_password") || !strings.Contains(out, "db1") {
+		t.Fatalf("unexpected ini output: %s", out)
+	}
+	if _, err := os.Stat(filepath.Join(iniVars, "db1", "vault.yml")); err != nil {
+		t.Fatal(err)
+	}
+
+	badVars := filepath.Join(dir, "bad_host_vars")
+	out, err = runCLI(t, "", "-i", state, "-f", "bad", "--host-vars-dir", badVars)
+	if err == nil || !strings.Contains(out, "unknown inventory format") {
+		t.Fatalf("expected format error: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(badVars); !os.IsNotExist(err) {
+		t.Fatalf("nothing should be written before the flags are checked: %v", err)
+	}
+}
+
+func TestCLISensitive(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+	data := `{"format_version":"1.0","values":{"root_module":{"resources":[
+		{"address":"ansible_host.db","type":"ansible_host","name":"db",
+		 "values":{"name":"db1","variables":{"db_password":"hunter2","os":"linux"}},
+		 "sensitive_values":{"variables":{"db_password":true}}}]}}}`
+	if err := os.WriteFile(state, []byte(data), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+		not  string
+	}{
+		{[]string{"-f", "ini"}, "db1 db_password=*** os=linux", "hunter2"},
+		{[]string{"-f", "ini", "--sensitive", "omit"}, "db1 os=linux", "db_password"},
+		{[]string{"-f", "ini", "--sensitive", "keep"}, "db1 db_password=hunter2 os=linux", "***"},
+		{[]string{"--list"}, `"db_password": "hunter2"`, "***"},
+		{[]string{"--list", "--sensitive", "mask"}, `"db_password": "***"`, "hunter2"},
+	}
+	for _, tt := range tests {
+		out, err := runCLI(t, "", append([]string{"-i", state}, tt.args...)...)
+		if err != nil {
+			t.Fatalf("%v: cli run err: %v\n%s", tt.args, err, out)
+		}
+		if !strings.Contains(out, tt.want) || strings.Contains(out, tt.not) {
+			t.Errorf("%v: unexpected output: %s", tt.args, out)
+		}
+	}
+
+	out, err := runCLIEnv(t, []string{stateEnv + "=" + state}, "", "--host", "db1")
+	if err != nil || !strings.Contains(out, `"db_password": "hunter2"`) {
+		t.Fatalf("host query should keep sensitive values for Ansible: %v\n%s", err, out)
+	}
+
+	out, err = runCLIEnv(t, []string{vaultPasswordEnv + "=pw"}, "", "-i", state, "--vault-pattern", "password")
+	if err != nil || !strings.Contains(out, "db_password: !vault |") {
+		t.Fatalf("vaulted values should not be masked: %v\n%s", err, out)
+	}
+	if out, err := runCLI(t, "", "-i", state, "--sensitive", "show"); err == nil {
+		t.Fatalf("expected error for unknown mode: %s", out)
+	}
+}
+
+func TestCLIInventoryDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "inventory")
+	out, err := runCLI(t, "", "-i", "smoketest.json", "--inventory-dir", dir)
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if out != "" {
+		t.Fatalf("nothing should be printed: %s", out)
+	}
+	want := map[string]string{
+		"hosts":               "[web]\ntest1\n\n",
+		"host_vars/test1.yml": "ansible_host: 192.168.1.10\nos: linux\n",
+		"group_vars/web.yml":  "tier: frontend\n",
+		"group_vars/all.yml":  "env: test\n",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q (%v), want %q", name, data, err, content)
+		}
+	}
+}
+
+func TestCLIDiff(t *testing.T) {
+	dir := t.TempDir()
+	newState := filepath.Join(dir, "new.json")
+	data := `{"values":{"root_module":{"resources":[
+		{"type":"ansible_host","values":{"name":"test1","groups":["web"],"variables":{"ip":"192.168.1.11","os":"linux"}}},
+		{"type":"ansible_host","values":{"name":"test2","groups":["web"]}}]}}}`
+	if err := os.WriteFile(newState, []byte(data), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	old, err := os.ReadFile("smoketest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCLI(t, string(old), "diff", "-", newState)
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		"hosts added (1): test2\n",
+		"group web:\n  + host test2\n  - tier (was \"frontend\")\n",
+		"host test1:\n  ~ ansible_host: \"192.168.1.10\" -> \"192.168.1.11\"\n",
+		"inventory:\n  - env (was \"test\")\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%s", want, out)
+		}
+	}
+
+	out, err = runCLI(t, "", "diff", "-f", "markdown", "--exit-code", "smoketest.json", newState)
+	if err == nil || !strings.Contains(out, "| `web` | `test2` |  |") {
+		t.Fatalf("expected exit code 1 and markdown output: %v\n%s", err, out)
+	}
+	if out, err := runCLI(t, "", "diff", "--exit-code", "smoketest.json", "smoketest.json"); err != nil || out != "no changes\n" {
+		t.Fatalf("equal inventories: %v\n%s", err, out)
+	}
+	if out, err := runCLI(t, "", "diff", "smoketest.json"); err == nil || !strings.Contains(out, "two inputs") {
+		t.Fatalf("expected usage error: %v\n%s", err, out)
+	}
+}
+
+func TestCLICheck(t *testing.T) {
+	dir := t.TempDir()
+	for _, format := range []string{"yaml", "ini", "json", "ansible"} {
+		out, err := runCLI(t, "", "-i", "smoketest.json", "-f", format)
+		if err != nil {
+			t.Fatalf("%s: cli run err: %v\n%s", format, err, out)
+		}
+		path := filepath.Join(dir, "inventory."+format)
+		if err := os.WriteFile(path, []byte(out), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := runCLI(t, "", "-i", "smoketest.json", "-f", format, "--check", path); err != nil || out != "" {
+			t.Fatalf("%s: up to date inventory reported as changed: %v\n%s", format, err, out)
+		}
+	}
+
+	// reordering and comments are not differences, a stale host is
+	ini := filepath.Join(dir, "inventory.ini")
+	stale := "# generated\n[web:vars]\ntier = frontend\n\n[all:vars]\nenv=test\n\n" +
+		"[web]\ntest1 os=linux ansible_host=192.168.1.10\nold1\n"
+	if err := os.WriteFile(ini, []byte(stale), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCLI(t, "", "-i", "smoketest.json", "-f", "ini", "--check", ini)
+	if err == nil || !strings.Contains(out, "hosts removed (1): old1\n") || !strings.Contains(out, "is out of date") {
+		t.Fatalf("expected stale inventory to fail: %v\n%s", err, out)
+	}
+
+	// vault values are compared by plaintext, not by ciphertext
+	env := []string{vaultPasswordEnv + "=s3cret"}
+	out, err = runCLIEnv(t, env, "", "-i", "smoketest.json", "--vault-pattern", "^os$")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	vaulted := filepath.Join(dir, "vault.yaml")
+	if err := os.WriteFile(vaulted, []byte(out), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := runCLIEnv(t, env, "", "-i", "smoketest.json", "--vault-pattern", "^os$", "--check", vaulted); err != nil {
+		t.Fatalf("vaulted inventory reported as changed: %v\n%s", err, out)
+	}
+
+	// the diff never prints plaintext secrets, neither decrypted nor kept
+	state := filepath.Join(dir, "state.json")
+	data := `{"format_version":"1.0","values":{"root_module":{"resources":[
+		{"address":"ansible_host.db","type":"ansible_host","name":"db",
+		 "values":{"name":"db1","variables":{"db_password":"new-secret","root_pw":"new-root"}},
+		 "sensitive_values":{"variables":{"db_password":true}}}]}}}`
+	if err := os.WriteFile(state, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := strings.NewReplacer("new-secret", "old-secret", "new-root", "old-root")
+	for _, args := range [][]string{
+		{"--sensitive", "keep"},
+		{"--vault-sensitive", "--vault-pattern", "_pw$"},
+	} {
+		out, err := runCLIEnv(t, env, "", append([]string{"-i", state}, args...)...)
+		if err != nil {
+			t.Fatalf("%v: cli run err: %v\n%s", args, err, out)
+		}
+		committed := filepath.Join(dir, "secrets.yaml")
+		stale := out
+		secrets := []string{"new-secret", "old-secret"}
+		if args[0] == "--sensitive" {
+			stale = old.Replace(out)
+		} else {
+			secrets = append(secrets, "new-root", "old-root")
+			stateOld := filepath.Join(dir, "old.json")
+			if err := os.WriteFile(stateOld, []byte(old.Replace(data)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if stale, err = runCLIEnv(t, env, "", append([]string{"-i", stateOld}, args...)...); err != nil {
+				t.Fatalf("%v: cli run err: %v\n%s", args, err, stale)
+			}
+		}
+		if err := os.WriteFile(committed, []byte(stale), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		out, err = runCLIEnv(t, env, "", append([]string{"-i", state, "--check", committed}, args...)...)
+		if err == nil || !strings.Contains(out, "db_password") || !strings.Contains(out, "root_pw") {
+			t.Fatalf("%v: expected changed secrets to be reported: %v\n%s", args, err, out)
+		}
+		for _, secret := range secrets {
+			if strings.Contains(out, secret) {
+				t.Fatalf("%v: check output leaks %s:\n%s", args, secret, out)
+			}
+		}
+	}
+
+	hostVars := filepath.Join(dir, "host_vars")
+	out, err = runCLI(t, "", "-i", "smoketest.json", "--check", ini, "--host-vars-dir", hostVars)
+	if err == nil || !strings.Contains(out, "cannot be combined") {
+		t.Fatalf("expected flag conflict: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(hostVars); !os.IsNotExist(err) {
+		t.Fatalf("--check must not write host_vars: %v", err)
+	}
+}
+
+func TestCLIOutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.ini")
+	out, err := runCLI(t, "", "-i", "smoketest.json", "-f", "ini", "-o", path, "--output-mode", "0600")
+	if err != nil || out != "" {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), "test1 ansible_host=192.168.1.10") {
+		t.Fatalf("unexpected file %q (%v)", data, err)
+	}
+	if fi, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600) {
+		t.Fatalf("unexpected mode: %v (%v)", fi.Mode(), err)
+	}
+
+	if out, err := runCLI(t, "", "-i", "smoketest.json", "-o", path, "--output-mode", "rw"); err == nil || !strings.Contains(out, "invalid --output-mode") {
+		t.Fatalf("expected mode error: %v\n%s", err, out)
+	}
+	if out, err := runCLI(t, "", "-i", "smoketest.json", "-o", path, "--check", path); err == nil || !strings.Contains(out, "cannot be combined") {
+		t.Fatalf("expected flag conflict: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/iohandler"
+	"github.com/urfave/cli/v2"
+)
+
+// diffCommand reports how the inventory built from one Terraform state
+// differs from the one built from another.
+func diffCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "diff",
+		Usage:     "Show how the inventory changes between two Terraform states",
+		ArgsUsage: "<old> <new>",
+		Flags: append(stateFlags(),
+			&cli.StringFlag{
+				Name:    "format",
+				Aliases: []string{"f"},
+				Value:   "text",
+				Usage:   "Diff format: text, json or markdown",
+			},
+			&cli.StringFlag{
+				Name:  "sensitive",
+				Value: "mask",
+				Usage: "How to show changes of sensitive variables: mask, omit, or keep",
+			},
+			&cli.BoolFlag{
+				Name:  "exit-code",
+				Usage: "Exit with status 1 when the inventories differ",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 2 {
+				return errors.New("diff needs two inputs: <old> <new>")
+			}
+			if c.Args().Get(0) == "-" && c.Args().Get(1) == "-" {
+				return errors.New("only one diff input can be read from stdin")
+			}
+			mode, err := inventory.ParseSensitiveMode(c.String("sensitive"))
+			if err != nil {
+				return err
+			}
+			cfg, err := loadConfig(c)
+			if err != nil {
+				return err
+			}
+			old, err := loadSpecs(c, cfg, []string{c.Args().Get(0)})
+			if err != nil {
+				return err
+			}
+			cur, err := loadSpecs(c, cfg, []string{c.Args().Get(1)})
+			if err != nil {
+				return err
+			}
+
+			d := cur.Compare(old)
+			d.Redact(mode)
+			if err := iohandler.OutputDiff(os.Stdout, d, strings.ToLower(c.String("format"))); err != nil {
+				return err
+			}
+			if c.Bool("exit-code") && !d.Empty() {
+				return cli.Exit("", 1)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/config"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/constructed"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/iohandler"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/parser"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/source"
+	"github.com/urfave/cli/v2"
+)
+
+// stateExtensions lists the file extensions picked up when an input names a
+// directory.
+var stateExtensions = []string{".json", ".tfstate"}
+
+// expandInputs resolves globs and directories into a list of state files.
+// Remote locations are passed through unchanged, as is "-", which may appear
+// only once.
+func expandInputs(specs []string) ([]string, error) {
+	var out []string
+	stdin := false
+	for _, spec := range specs {
+		switch {
+		case spec == "-":
+			if stdin {
+				return nil, fmt.Errorf("stdin can only be used as input once")
+			}
+			stdin = true
+			out = append(out, spec)
+		case source.IsRemote(spec):
+			out = append(out, spec)
+		case strings.ContainsAny(spec, "*?["):
+			matches, err := filepath.Glob(spec)
+			if err != nil {
+				return nil, fmt.Errorf("invalid input pattern %q: %w", spec, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("input pattern %q matches no files", spec)
+			}
+			out = append(out, matches...)
+		default:
+			fi, err := os.Stat(spec)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %q: %w", spec, err)
+			}
+			if !fi.IsDir() {
+				out = append(out, spec)
+				continue
+			}
+			files, err := stateFiles(spec)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, files...)
+		}
+	}
+	return out, nil
+}
+
+// stateFiles returns the state files directly inside dir, sorted by name.
+func stateFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", dir, err)
+	}
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		for _, ext := range stateExtensions {
+			if strings.HasSuffix(e.Name(), ext) {
+				files = append(files, filepath.Join(dir, e.Name()))
+				break
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("directory %q contains no state files", dir)
+	}
+	return files, nil
+}
+
+// loadInventory parses every input with the given resource rules and merges
+// the results in order. The returned map lists, for each host, the inputs
+// that defined it.
+func loadInventory(ctx context.Context, paths []string, rules []parser.Rule, policy inventory.ConflictPolicy, opts source.Options) (*inventory.Inventory, map[string][]string, error) {
+	inv := inventory.New()
+	sources := make(map[string][]string)
+	for _, path := range paths {
+		part, err := parseInput(ctx, path, rules, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := inv.Merge(part, policy); err != nil {
+			return nil, nil, fmt.Errorf("merging %q: %w", path, err)
+		}
+		for name := range part.Hosts {
+			sources[name] = append(sources[name], path)
+		}
+	}
+	return inv, sources, nil
+}
+
+func parseInput(ctx context.Context, path string, rules []parser.Rule, opts source.Options) (*inventory.Inventory, error) {
+	r, err := source.Open(ctx, path, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	inv, err := parser.ParseInventoryReaderWithOptions(r, parser.Options{Source: path, Rules: rules})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", path, err)
+	}
+	return inv, nil
+}
+
+// importInventories merges existing Ansible inventories into inv after the
+// Terraform states and records them in sources.
+func importInventories(inv *inventory.Inventory, sources map[string][]string, paths []string, policy inventory.ConflictPolicy) error {
+	for _, path := range paths {
+		part, err := iohandler.ReadInventoryFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to import %q: %w", path, err)
+		}
+		if err := inv.Merge(part, policy); err != nil {
+			return fmt.Errorf("merging %q: %w", path, err)
+		}
+		for name := range part.Hosts {
+			sources[name] = append(sources[name], path)
+		}
+	}
+	return nil
+}
+
+// writeSourceReport prints which inputs each host came from.
+func writeSourceReport(w io.Writer, sources map[string][]string) error {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", name, strings.Join(sources[name], ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inputFlags returns the flags that select and fetch the Terraform states.
+// They are shared by the root command and every subcommand reading state.
+func inputFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Usage:   "Path, glob, directory, HTTP backend URL or s3://bucket/key of Terraform state (or '-' for stdin); repeatable, defaults to $" + stateEnv,
+		},
+	}, stateFlags()...)
+}
+
+// stateFlags returns the flags that fetch, merge and post-process the
+// Terraform states, for commands that take their inputs as arguments.
+func stateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "http-username",
+			EnvVars: []string{"TF_HTTP_USERNAME"},
+			Usage:   "Username for basic authentication against an HTTP state backend",
+		},
+		&cli.StringFlag{
+			Name:    "http-password",
+			EnvVars: []string{"TF_HTTP_PASSWORD"},
+			Usage:   "Password for basic authentication against an HTTP state backend",
+		},
+		&cli.StringSliceFlag{
+			Name:  "http-header",
+			Usage: "Extra request header for HTTP state backends as 'Name: value'; repeatable",
+		},
+		&cli.StringFlag{
+			Name:  "http-ca-cert",
+			Usage: "PEM file with CA certificates to verify the HTTP state backend",
+		},
+		&cli.StringFlag{
+			Name:  "http-client-cert",
+			Usage: "PEM client certificate for mutual TLS with the HTTP state backend",
+		},
+		&cli.StringFlag{
+			Name:  "http-client-key",
+			Usage: "PEM private key for --http-client-cert",
+		},
+		&cli.BoolFlag{
+			Name:  "http-skip-verify",
+			Usage: "Skip TLS certificate verification of the HTTP state backend",
+		},
+		&cli.DurationFlag{
+			Name:  "remote-timeout",
+			Value: source.DefaultTimeout,
+			Usage: "Maximum time to fetch one HTTP or S3 state input, e.g. 30s or 2m",
+		},
+		&cli.StringFlag{
+			Name:    "s3-region",
+			EnvVars: []string{"AWS_REGION", "AWS_DEFAULT_REGION"},
+			Usage:   "Region of s3:// state inputs",
+		},
+		&cli.StringFlag{
+			Name:    "s3-endpoint",
+			EnvVars: []string{"AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"},
+			Usage:   "Custom S3 endpoint, e.g. a MinIO server",
+		},
+		&cli.BoolFlag{
+			Name:  "s3-path-style",
+			Usage: "Use path-style addressing for s3:// state inputs",
+		},
+		&cli.StringFlag{
+			Name:    "workspace",
+			EnvVars: []string{"TF_WORKSPACE"},
+			Usage:   "Terraform workspace of s3:// state inputs",
+		},
+		&cli.StringFlag{
+			Name:  "workspace-key-prefix",
+			Value: source.DefaultWorkspaceKeyPrefix,
+			Usage: "Key prefix of non-default workspaces in S3",
+		},
+		&cli.StringSliceFlag{
+			Name:  "import",
+			Usage: "Existing Ansible inventory (YAML, INI or JSON) merged after the Terraform states, e.g. for bare metal hosts; repeatable",
+		},
+		&cli.StringFlag{
+			Name:  "on-conflict",
+			Value: "last-wins",
+			Usage: "How to merge variables defined in several inputs: last-wins, first-wins, or error",
+		},
+		&cli.BoolFlag{
+			Name:  "source-report",
+			Usage: "Print the input each host came from to stderr",
+		},
+		&cli.StringFlag{
+			Name:    "config",
+			Aliases: []string{"c"},
+			EnvVars: []string{configEnv},
+			Usage:   "Path to a YAML config file",
+		},
+	}
+}
+
+// loadFromContext loads the config file, resolves the inputs and returns the
+// merged inventory of all Terraform states along with the config. Terraform
+// metadata variables (--tf-metadata), the address mapping and the compose and
+// constructed groups of the config are applied before returning.
+func loadFromContext(c *cli.Context) (*inventory.Inventory, *config.Config, error) {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	specs := resolveInputs(c, cfg)
+	if len(specs) == 0 {
+		return nil, nil, errors.New("no input given: set --input, $" + stateEnv + " or input in the config file")
+	}
+	inv, err := loadSpecs(c, cfg, specs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inv, cfg, nil
+}
+
+// loadConfig reads the config file named by --config, if any.
+func loadConfig(c *cli.Context) (*config.Config, error) {
+	path := c.String("config")
+	if path == "" {
+		return &config.Config{}, nil
+	}
+	return config.Load(path)
+}
+
+// loadSpecs expands and parses the given inputs, merges them and applies
+// the settings of c and cfg as described for loadFromContext.
+func loadSpecs(c *cli.Context, cfg *config.Config, specs []string) (*inventory.Inventory, error) {
+	paths, err := expandInputs(specs)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := inventory.ParseConflictPolicy(c.String("on-conflict"))
+	if err != nil {
+		return nil, err
+	}
+	resourceRules, err := cfg.ParserRules()
+	if err != nil {
+		return nil, err
+	}
+
+	inv, sources, err := loadInventory(c.Context, paths, resourceRules, policy, sourceOptions(c))
+	if err != nil {
+		return nil, err
+	}
+	if err := importInventories(inv, sources, resolveImports(c, cfg), policy); err != nil {
+		return nil, err
+	}
+	if c.Bool("source-report") {
+		if err := writeSourceReport(os.Stderr, sources); err != nil {
+			return nil, err
+		}
+	}
+	if c.Bool("tf-metadata") {
+		inv.MetadataToVars("tf_")
+	}
+
+	mapping, err := cfg.Address.AddressMapping()
+	if err != nil {
+		return nil, err
+	}
+	inv.MapAddresses(mapping)
+
+	rules, err := constructed.Compile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !rules.Empty() {
+		if err := rules.Apply(inv); err != nil {
+			return nil, err
+		}
+	}
+	return inv, nil
+}
+
+// resolveInputs returns the state inputs from --input, the environment or the
+// config file, in that order. The environment variable may hold several
+// paths separated by the OS path list separator.
+func resolveInputs(c *cli.Context, cfg *config.Config) []string {
+	if specs := c.StringSlice("input"); len(specs) > 0 {
+		return specs
+	}
+	if env := os.Getenv(stateEnv); env != "" {
+		return filepath.SplitList(env)
+	}
+	return cfg.Input
+}
+
+// resolveImports returns the inventories to import from --import or the
+// config file.
+func resolveImports(c *cli.Context, cfg *config.Config) []string {
+	if paths := c.StringSlice("import"); len(paths) > 0 {
+		return paths
+	}
+	return cfg.Import
+}
+
+// sourceOptions collects the settings for remote state inputs.
+func sourceOptions(c *cli.Context) source.Options {
+	return source.Options{
+		HTTP: source.HTTPOptions{
+			Username:   c.String("http-username"),
+			Password:   c.String("http-password"),
+			Headers:    c.StringSlice("http-header"),
+			CACert:     c.String("http-ca-cert"),
+			ClientCert: c.String("http-client-cert"),
+			ClientKey:  c.String("http-client-key"),
+			SkipVerify: c.Bool("http-skip-verify"),
+		},
+		S3: source.S3Options{
+			Region:             c.String("s3-region"),
+			Endpoint:           c.String("s3-endpoint"),
+			PathStyle:          c.Bool("s3-path-style"),
+			Workspace:          c.String("workspace"),
+			WorkspaceKeyPrefix: c.String("workspace-key-prefix"),
+			Credentials: source.Credentials{
+				AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			},
+		},
+		Timeout: c.Duration("remote-timeout"),
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/source"
+)
+
+func writeState(t *testing.T, dir, name, host string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	state := `{"type":"ansible_host","values":{"name":"` + host + `","groups":["web"],"variables":{"ip":"10.0.0.1"}}}`
+	if err := os.WriteFile(path, []byte(state), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	return path
+}
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	a := writeState(t, dir, "a.tfstate", "h1")
+	b := writeState(t, dir, "b.json", "h2")
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	got, err := expandInputs([]string{dir})
+	if err != nil {
+		t.Fatalf("expand dir: %v", err)
+	}
+	if len(got) != 2 || got[0] != a || got[1] != b {
+		t.Fatalf("unexpected dir expansion: %v", got)
+	}
+
+	got, err = expandInputs([]string{filepath.Join(dir, "*.tfstate"), "-"})
+	if err != nil {
+		t.Fatalf("expand glob: %v", err)
+	}
+	if len(got) != 2 || got[0] != a || got[1] != "-" {
+		t.Fatalf("unexpected glob expansion: %v", got)
+	}
+
+	if _, err := expandInputs([]string{filepath.Join(dir, "*.none")}); err == nil {
+		t.Fatal("expected error for glob without matches")
+	}
+	if _, err := expandInputs([]string{"-", "-"}); err == nil {
+		t.Fatal("expected error for repeated stdin")
+	}
+}
+
+func TestLoadInventoryMergesSources(t *testing.T) {
+	dir := t.TempDir()
+	a := writeState(t, dir, "a.tfstate", "h1")
+	b := writeState(t, dir, "b.tfstate", "h1")
+	c := writeState(t, dir, "c.tfstate", "h2")
+
+	inv, sources, err := loadInventory(context.Background(), []string{a, b, c}, nil, inventory.LastWins, source.Options{})
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if len(inv.Hosts) != 2 || len(inv.Groups["web"].Hosts) != 2 {
+		t.Fatalf("unexpected merged inventory: %#v", inv.Hosts)
+	}
+	var buf bytes.Buffer
+	if err := writeSourceReport(&buf, sources); err != nil {
+		t.Fatalf("report error: %v", err)
+	}
+	want := "h1\t" + a + "," + b + "\nh2\t" + c + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+}
+
+func TestCLIMultipleInputs(t *testing.T) {
+	dir := t.TempDir()
+	writeState(t, dir, "a.tfstate", "h1")
+	writeState(t, dir, "b.tfstate", "h2")
+	out, err := runCLI(t, "", "-i", dir, "-i", "smoketest.json", "-f", "ini")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	for _, want := range []string{"h1 ansible_host", "h2 ansible_host", "test1 ansible_host"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestCLIImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bare-metal.ini")
+	hosts := "[metal]\nmetal[1:2] ansible_host=10.1.0.1\n\n[web]\ntest1 os=bsd\n"
+	if err := os.WriteFile(path, []byte(hosts), 0o644); err != nil {
+		t.Fatalf("write inventory: %v", err)
+	}
+
+	out, err := runCLI(t, "", "-i", "smoketest.json", "--import", path, "-f", "ini")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	for _, want := range []string{"[metal]\nmetal1 ansible_host=10.1.0.1\nmetal2 ansible_host=10.1.0.1\n", "test1 ansible_host=192.168.1.10 os=bsd\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	out, err = runCLI(t, "", "-i", "smoketest.json", "--import", path, "--on-conflict", "error")
+	if err == nil || !strings.Contains(out, "bare-metal.ini") {
+		t.Fatalf("expected conflict error: %v\n%s", err, out)
+	}
+}
+
+func TestCLIHTTPInput(t *testing.T) {
+	data, err := os.ReadFile("smoketest.json")
+	if err != nil {
+		t.Fatalf("read smoketest: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "ci" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	out, err := runCLIEnv(t, []string{"TF_HTTP_PASSWORD=secret"}, "", "-i", srv.URL+"/state/app", "--http-username", "ci", "-f", "ini")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "test1 ansible_host=192.168.1.10") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCLIS3Input(t *testing.T) {
+	data, err := os.ReadFile("smoketest.json")
+	if err != nil {
+		t.Fatalf("read smoketest: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tfstate/env:/prod/app.tfstate" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	env := []string{"AWS_ACCESS_KEY_ID=minio", "AWS_SECRET_ACCESS_KEY=minio123", "AWS_ENDPOINT_URL_S3=" + srv.URL}
+	out, err := runCLIEnv(t, env, "", "-i", "s3://tfstate/app.tfstate", "--s3-path-style", "--workspace", "prod", "-f", "ini")
+	if err != nil {
+		t.Fatalf("cli run err: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "test1 ansible_host=192.168.1.10") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/parser"
+)
+
+// Config holds settings read from an optional YAML configuration file. It
+// allows the tool to run without command line flags, e.g. when Ansible
+// executes it as a dynamic inventory script.
+type Config struct {
+	// Input lists the Terraform states to read. A single path may be given
+	// as a plain string.
+	Input StringList `yaml:"input"`
+
+	// Import lists existing Ansible inventories merged with the Terraform
+	// states, for hosts not managed by Terraform.
+	Import StringList `yaml:"import"`
+
+	// Address configures how the connection address of each host is
+	// derived. Without it the ip variable becomes ansible_host.
+	Address *Address `yaml:"address"`
+
+	// Rules build hosts from resources other than the ansible/ansible
+	// provider's, such as aws_instance or hcloud_server.
+	Rules []ResourceRule `yaml:"rules"`
+
+	// Compose maps host variable names to expressions computing their
+	// value from the host's existing variables.
+	Compose map[string]string `yaml:"compose"`
+	// Groups maps group names to expressions; every host for which the
+	// expression is true is added to the group.
+	Groups map[string]string `yaml:"groups"`
+	// KeyedGroups creates groups named after the values of expressions.
+	KeyedGroups []KeyedGroup `yaml:"keyed_groups"`
+	// Strict turns errors while evaluating compose, groups and keyed_groups
+	// into failures instead of skipping the host.
+	Strict bool `yaml:"strict"`
+	// LeadingSeparator keeps the separator in front of keyed group names
+	// without a prefix, e.g. "_linux". It defaults to true like in Ansible.
+	LeadingSeparator *bool `yaml:"leading_separator"`
+}
+
+// Address describes the mapping of address variables to the connection
+// variable, see inventory.AddressMapping.
+type Address struct {
+	// Sources lists the variables holding addresses in order of preference.
+	// A single variable may be given as a plain string. Defaults to ip.
+	Sources StringList `yaml:"sources"`
+	// Family is the preferred address family: any, ipv4 or ipv6.
+	Family string `yaml:"family"`
+	// Target is the variable to set, ansible_host by default.
+	Target string `yaml:"target"`
+	// StripCIDR removes a "/prefix" suffix, true by default.
+	StripCIDR *bool `yaml:"strip_cidr"`
+	// KeepSources keeps the source variables in the output.
+	KeepSources bool `yaml:"keep_sources"`
+}
+
+// AddressMapping converts the address section into an
+// inventory.AddressMapping, filling in the defaults. A nil section yields
+// inventory.DefaultAddressMapping.
+func (a *Address) AddressMapping() (inventory.AddressMapping, error) {
+	m := inventory.DefaultAddressMapping()
+	if a == nil {
+		return m, nil
+	}
+	if len(a.Sources) > 0 {
+		m.Sources = a.Sources
+	}
+	if a.Target != "" {
+		m.Target = a.Target
+	}
+	if a.StripCIDR != nil {
+		m.StripCIDR = *a.StripCIDR
+	}
+	m.KeepSources = a.KeepSources
+	family, err := inventory.ParseAddressFamily(a.Family)
+	if err != nil {
+		return m, fmt.Errorf("invalid config: %w", err)
+	}
+	m.Family = family
+	return m, nil
+}
+
+// ResourceRule describes how resources of some types become hosts, see
+// parser.Rule. Name, Vars and Groups are dot paths such as
+// values.tags.Name.
+type ResourceRule struct {
+	// Types lists the resource types; a single type may be given as a
+	// plain string.
+	Types StringList `yaml:"types"`
+	// Name is the path of the host name.
+	Name string `yaml:"name"`
+	// Vars maps host variable names to paths.
+	Vars map[string]string `yaml:"vars"`
+	// Groups lists paths of group names; a single path may be given as a
+	// plain string.
+	Groups StringList `yaml:"groups"`
+}
+
+// ParserRules converts the rules section into parser rules, checking that
+// every rule names its types and host name.
+func (c *Config) ParserRules() ([]parser.Rule, error) {
+	rules := make([]parser.Rule, 0, len(c.Rules))
+	for i, r := range c.Rules {
+		if len(r.Types) == 0 {
+			return nil, fmt.Errorf("invalid config: rules[%d]: types is required", i)
+		}
+		if r.Name == "" {
+			return nil, fmt.Errorf("invalid config: rules[%d]: name is required", i)
+		}
+		rules = append(rules, parser.Rule{
+			Types:  r.Types,
+			Name:   r.Name,
+			Vars:   r.Vars,
+			Groups: r.Groups,
+		})
+	}
+	return rules, nil
+}
+
+// KeyedGroup describes groups created from the value of an expression, like
+// an entry of the keyed_groups option of Ansible's constructed plugin.
+type KeyedGroup struct {
+	// Key is evaluated per host. A string yields one group, a list one
+	// group per item and a map one group per "key<separator>value" pair.
+	Key string `yaml:"key"`
+	// Prefix is put in front of the value, joined by Separator.
+	Prefix string `yaml:"prefix"`
+	// Separator defaults to "_".
+	Separator *string `yaml:"separator"`
+	// DefaultValue replaces empty values.
+	DefaultValue *string `yaml:"default_value"`
+	// TrailingSeparator set to false drops the separator for empty map
+	// values instead of producing "key_".
+	TrailingSeparator *bool `yaml:"trailing_separator"`
+	// ParentGroup, when set, becomes the parent of every created group.
+	ParentGroup string `yaml:"parent_group"`
+}
+
+// StringList is a list of strings that may also be written as a single
+// scalar in YAML.
+type StringList []string
+
+// UnmarshalYAML accepts both a scalar and a sequence of scalars.
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = StringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Load reads the YAML configuration file at path. Unknown keys are rejected
+// so typos do not go unnoticed.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %q: %w", path, err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a YAML configuration document.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cfg.yml")
+	if err := os.WriteFile(path, []byte("input: state.json\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if len(cfg.Input) != 1 || cfg.Input[0] != "state.json" {
+		t.Fatalf("unexpected input: %q", cfg.Input)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	cfg, err := Parse(nil)
+	if err != nil || cfg == nil {
+		t.Fatalf("empty config should parse: %v", err)
+	}
+}
+
+func TestParseUnknownKey(t *testing.T) {
+	if _, err := Parse([]byte("inptu: x\n")); err == nil {
+		t.Fatal("expected error for unknown key")
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestParseInputList(t *testing.T) {
+	cfg, err := Parse([]byte("input:\n  - a.tfstate\n  - states/\n"))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if len(cfg.Input) != 2 || cfg.Input[1] != "states/" {
+		t.Fatalf("unexpected inputs: %v", cfg.Input)
+	}
+}
+
+func TestParseImport(t *testing.T) {
+	cfg, err := Parse([]byte("import: inventory/bare-metal.ini\n"))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if len(cfg.Import) != 1 || cfg.Import[0] != "inventory/bare-metal.ini" {
+		t.Fatalf("unexpected imports: %v", cfg.Import)
+	}
+}
+
+func TestParseConstructed(t *testing.T) {
+	cfg, err := Parse([]byte(`
+groups:
+  linux: os == "linux"
+keyed_groups:
+  - key: os
+    prefix: os
+    separator: "-"
+leading_separator: false
+`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if cfg.Groups["linux"] != `os == "linux"` {
+		t.Fatalf("unexpected groups: %v", cfg.Groups)
+	}
+	if len(cfg.KeyedGroups) != 1 || cfg.KeyedGroups[0].Prefix != "os" || *cfg.KeyedGroups[0].Separator != "-" {
+		t.Fatalf("unexpected keyed groups: %+v", cfg.KeyedGroups)
+	}
+	if cfg.LeadingSeparator == nil || *cfg.LeadingSeparator {
+		t.Fatalf("leading_separator not read")
+	}
+}
+
+func TestAddressMapping(t *testing.T) {
+	cfg, err := Parse([]byte("address:\n  sources: [private_ip, ip]\n  family: ipv6\n  strip_cidr: false\n"))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	m, err := cfg.Address.AddressMapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Sources) != 2 || m.Sources[0] != "private_ip" || m.Family != inventory.IPv6 || m.StripCIDR || m.Target != "ansible_host" {
+		t.Fatalf("unexpected mapping: %+v", m)
+	}
+
+	var none *Address
+	if m, _ := none.AddressMapping(); len(m.Sources) != 1 || m.Sources[0] != "ip" || !m.StripCIDR {
+		t.Fatalf("unexpected default mapping: %+v", m)
+	}
+
+	cfg, _ = Parse([]byte("address:\n  family: ipx\n"))
+	if _, err := cfg.Address.AddressMapping(); err == nil {
+		t.Fatal("expected error for unknown family")
+	}
+}
+
+func TestParserRules(t *testing.T) {
+	cfg, err := Parse([]byte(`rules:
+  - types: [aws_instance, hcloud_server]
+    name: values.tags.Name
+    vars:
+      ansible_host: values.private_ip
+    groups: values.tags.Role
+`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	rules, err := cfg.ParserRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || len(rules[0].Types) != 2 || rules[0].Name != "values.tags.Name" ||
+		rules[0].Vars["ansible_host"] != "values.private_ip" || len(rules[0].Groups) != 1 {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+
+	for _, data := range []string{
+		"rules:\n  - name: values.name\n",
+		"rules:\n  - types: aws_instance\n",
+	} {
+		cfg, err := Parse([]byte(data))
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := cfg.ParserRules(); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Package constructed derives host variables and groups from existing host
+// variables, replicating the compose, groups and keyed_groups options of
+// Ansible's constructed inventory plugin.
+package constructed
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/config"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/expr"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+// Rules are the compiled compose, groups and keyed_groups of a config file.
+type Rules struct {
+	compose          []composedVar
+	groups           []conditionalGroup
+	keyed            []keyedGroup
+	strict           bool
+	leadingSeparator bool
+}
+
+type composedVar struct {
+	name  string
+	value *expr.Expr
+}
+
+type conditionalGroup struct {
+	name string
+	cond *expr.Expr
+}
+
+type keyedGroup struct {
+	config.KeyedGroup
+	key       *expr.Expr
+	separator string
+}
+
+// Compile parses the expressions of cfg.
+func Compile(cfg *config.Config) (*Rules, error) {
+	r := &Rules{strict: cfg.Strict, leadingSeparator: true}
+	if cfg.LeadingSeparator != nil {
+		r.leadingSeparator = *cfg.LeadingSeparator
+	}
+
+	for _, name := range sortedKeys(cfg.Compose) {
+		e, err := expr.Parse(cfg.Compose[name])
+		if err != nil {
+			return nil, fmt.Errorf("compose %s: %w", name, err)
+		}
+		r.compose = append(r.compose, composedVar{name: name, value: e})
+	}
+	for _, name := range sortedKeys(cfg.Groups) {
+		e, err := expr.Parse(cfg.Groups[name])
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", name, err)
+		}
+		r.groups = append(r.groups, conditionalGroup{name: name, cond: e})
+	}
+
+	for i, kg := range cfg.KeyedGroups {
+		if kg.Key == "" {
+			return nil, fmt.Errorf("keyed_groups[%d]: key is required", i)
+		}
+		if kg.DefaultValue != nil && kg.TrailingSeparator != nil {
+			return nil, fmt.Errorf("keyed_groups[%d]: default_value and trailing_separator are mutually exclusive", i)
+		}
+		e, err := expr.Parse(kg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("keyed_groups[%d]: %w", i, err)
+		}
+		k := keyedGroup{KeyedGroup: kg, key: e, separator: "_"}
+		if kg.Separator != nil {
+			k.separator = *kg.Separator
+		}
+		r.keyed = append(r.keyed, k)
+	}
+	return r, nil
+}
+
+// Empty reports whether the rules would not change any inventory.
+func (r *Rules) Empty() bool {
+	return len(r.compose) == 0 && len(r.groups) == 0 && len(r.keyed) == 0
+}
+
+// Apply sets the composed variables of every host and adds it to the groups
+// its variables select. Like in Ansible, compose expressions are evaluated
+// against the variables returned by Inventory.HostVars and stored as host
+// variables; conditional and then keyed groups see the result. Composed
+// values that are null are not set, and values computed from a sensitive
+// variable are marked sensitive themselves. Group names are made safe with
+// inventory.SafeGroupName. Unless the rules are strict, expressions that fail
+// to evaluate are skipped.
+func (r *Rules) Apply(inv *inventory.Inventory) error {
+	for _, host := range sortedKeys(inv.Hosts) {
+		vars := inv.HostVars(host)
+		if len(r.compose) > 0 {
+			h := inv.Hosts[host]
+			sensitive := inv.HostSensitive(host)
+			for _, c := range r.compose {
+				v, err := c.value.Eval(vars)
+				if err != nil {
+					if r.strict {
+						return fmt.Errorf("compose %s, host %s: %w", c.name, host, err)
+					}
+					continue
+				}
+				if v == nil {
+					continue
+				}
+				h.Variables[c.name] = v
+				if referencesSensitive(c.value, sensitive) {
+					if h.Sensitive == nil {
+						h.Sensitive = make(map[string]bool)
+					}
+					h.Sensitive[c.name] = true
+				}
+			}
+			vars = inv.HostVars(host)
+		}
+		for _, g := range r.groups {
+			ok, err := g.cond.Match(vars)
+			if err != nil {
+				if r.strict {
+					return fmt.Errorf("group %s, host %s: %w", g.name, host, err)
+				}
+				continue
+			}
+			if ok {
+				addToGroup(inv, host, inventory.SafeGroupName(g.name))
+			}
+		}
+		for _, k := range r.keyed {
+			names, err := r.keyedNames(k, vars)
+			if err != nil {
+				if r.strict {
+					return fmt.Errorf("keyed group %s, host %s: %w", k.Key, host, err)
+				}
+				continue
+			}
+			for _, name := range names {
+				addToGroup(inv, host, name)
+				if k.ParentGroup != "" {
+					inv.AddGroup(&inventory.Group{
+						Name:     inventory.SafeGroupName(k.ParentGroup),
+						Children: []string{name},
+					})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var errEmptyKey = errors.New("key is undefined or empty")
+
+// keyedNames returns the sanitized group names a keyed group yields for a
+// host with the given variables.
+func (r *Rules) keyedNames(k keyedGroup, vars map[string]any) ([]string, error) {
+	v, err := k.key.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []string
+	switch key := v.(type) {
+	case nil:
+		if k.DefaultValue == nil {
+			return nil, errEmptyKey
+		}
+		raw = append(raw, *k.DefaultValue)
+	case []any:
+		for _, item := range key {
+			s, err := scalarString(item)
+			if err != nil {
+				return nil, err
+			}
+			raw = append(raw, k.orDefault(s))
+		}
+	case map[string]any:
+		for _, name := range sortedKeys(key) {
+			s, err := scalarString(key[name])
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case s != "":
+				raw = append(raw, name+k.separator+s)
+			case k.DefaultValue != nil:
+				raw = append(raw, name+k.separator+*k.DefaultValue)
+			case k.TrailingSeparator != nil && !*k.TrailingSeparator:
+				raw = append(raw, name)
+			default:
+				raw = append(raw, name+k.separator)
+			}
+		}
+	default:
+		s, err := scalarString(key)
+		if err != nil {
+			return nil, err
+		}
+		if s == "" && k.DefaultValue == nil {
+			return nil, errEmptyKey
+		}
+		raw = append(raw, k.orDefault(s))
+	}
+
+	sep := k.separator
+	if k.Prefix == "" && !r.leadingSeparator {
+		sep = ""
+	}
+	out := make([]string, 0, len(raw))
+	for _, name := range raw {
+		out = append(out, inventory.SafeGroupName(k.Prefix+sep+name))
+	}
+	return out, nil
+}
+
+func (k keyedGroup) orDefault(s string) string {
+	if s == "" && k.DefaultValue != nil {
+		return *k.DefaultValue
+	}
+	return s
+}
+
+// scalarString renders strings, numbers and booleans for use in a group
+// name.
+func scalarString(v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return x, nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(x), nil
+	}
+	return "", fmt.Errorf("cannot build a group name from %T", v)
+}
+
+// addToGroup adds host to the named group, declaring the group so that it
+// is not reported as undeclared.
+func addToGroup(inv *inventory.Inventory, host, group string) {
+	inv.AddGroup(&inventory.Group{Name: group, Hosts: []string{host}})
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// referencesSensitive reports whether e refers to a sensitive variable or to
+// a field of one.
+func referencesSensitive(e *expr.Expr, sensitive map[string]bool) bool {
+	for _, name := range e.Vars() {
+		root, _, _ := strings.Cut(name, ".")
+		if sensitive[name] || sensitive[root] {
+			return true
+		}
+	}
+	return false
+}
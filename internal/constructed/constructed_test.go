@@ -0,0 +1,222 @@
+package constructed
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/config"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+func fixture() *inventory.Inventory {
+	inv := inventory.New()
+	inv.AddVars(map[string]any{"env": "prod"})
+	inv.AddGroup(&inventory.Group{Name: "debian", Variables: map[string]any{"os": "linux"}})
+	inv.AddHost(&inventory.Host{Name: "web1", Groups: []string{"debian"}, Variables: map[string]any{
+		"distro": "Debian-12",
+		"cpus":   float64(4),
+		"roles":  []any{"web", "cache"},
+		"tags":   map[string]any{"team": "ops", "cost": ""},
+	}})
+	inv.AddHost(&inventory.Host{Name: "db1", Variables: map[string]any{"os": "windows", "distro": ""}})
+	return inv
+}
+
+func compile(t *testing.T, yml string) *Rules {
+	t.Helper()
+	cfg, err := config.Parse([]byte(yml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Compile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func groupHosts(inv *inventory.Inventory) string {
+	var out []string
+	for name, g := range inv.Groups {
+		hosts := append([]string(nil), g.Hosts...)
+		sort.Strings(hosts)
+		out = append(out, name+"="+strings.Join(hosts, ","))
+	}
+	sort.Strings(out)
+	return strings.Join(out, " ")
+}
+
+func TestApplyGroups(t *testing.T) {
+	r := compile(t, `
+groups:
+  linux: os == "linux"
+  big-iron: cpus >= 4 && env == "prod"
+  broken: cpus > "x"
+`)
+	inv := fixture()
+	if err := r.Apply(inv); err != nil {
+		t.Fatal(err)
+	}
+	if got := groupHosts(inv); got != "big_iron=web1 debian=web1 linux=web1" {
+		t.Fatalf("unexpected groups: %s", got)
+	}
+	if !inv.Groups["linux"].Declared {
+		t.Fatalf("constructed groups must be declared")
+	}
+}
+
+func TestApplyKeyedGroups(t *testing.T) {
+	r := compile(t, `
+keyed_groups:
+  - key: os
+    prefix: os
+  - key: distro
+    prefix: distro
+    separator: "-"
+    default_value: unknown
+    parent_group: distros
+  - key: roles
+  - key: tags
+    prefix: tag
+    trailing_separator: false
+`)
+	inv := fixture()
+	if err := r.Apply(inv); err != nil {
+		t.Fatal(err)
+	}
+	want := "_cache=web1 _web=web1 debian=web1 distro_Debian_12=web1 distro_unknown=db1 distros= " +
+		"os_linux=web1 os_windows=db1 tag_cost=web1 tag_team_ops=web1"
+	if got := groupHosts(inv); got != want {
+		t.Fatalf("unexpected groups:\n got %s\nwant %s", got, want)
+	}
+	children := append([]string(nil), inv.Groups["distros"].Children...)
+	sort.Strings(children)
+	if strings.Join(children, ",") != "distro_Debian_12,distro_unknown" {
+		t.Fatalf("unexpected parent children: %v", children)
+	}
+}
+
+func TestLeadingSeparator(t *testing.T) {
+	r := compile(t, `
+leading_separator: false
+keyed_groups:
+  - key: roles
+`)
+	inv := fixture()
+	if err := r.Apply(inv); err != nil {
+		t.Fatal(err)
+	}
+	if inv.Groups["web"] == nil || inv.Groups["cache"] == nil {
+		t.Fatalf("unexpected groups: %s", groupHosts(inv))
+	}
+}
+
+func TestStrict(t *testing.T) {
+	r := compile(t, `
+strict: true
+keyed_groups:
+  - key: missing
+`)
+	if err := r.Apply(fixture()); err == nil {
+		t.Fatal("expected error for undefined key")
+	}
+	r = compile(t, `
+strict: true
+groups:
+  bad: cpus > "x"
+`)
+	if err := r.Apply(fixture()); err == nil {
+		t.Fatal("expected error for failing condition")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, yml := range []string{
+		"groups:\n  g: 'os =='\n",
+		"keyed_groups:\n  - prefix: x\n",
+		"keyed_groups:\n  - key: os\n    default_value: x\n    trailing_separator: false\n",
+	} {
+		cfg, err := config.Parse([]byte(yml))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Compile(cfg); err == nil {
+			t.Errorf("expected error for %q", yml)
+		}
+	}
+}
+
+func TestApplyCompose(t *testing.T) {
+	r := compile(t, `
+compose:
+  ansible_host: strip_cidr(ip)
+  ansible_user: default(admin_user, "debian")
+  ansible_port: int(default(ssh_port, "22"))
+  fqdn: format("{}.{}", lower(distro), env + ".example.com")
+  missing: unknown_var
+  broken: ip + undefined
+groups:
+  custom_port: ansible_port != 22
+`)
+	inv := fixture()
+	inv.Hosts["web1"].Variables["ip"] = "10.0.0.1/24"
+	inv.Hosts["db1"].Variables["ssh_port"] = "2222"
+	inv.Hosts["db1"].Variables["admin_user"] = "Administrator"
+	if err := r.Apply(inv); err != nil {
+		t.Fatal(err)
+	}
+	web := inv.Hosts["web1"].Variables
+	if web["ansible_host"] != "10.0.0.1" || web["ansible_user"] != "debian" || web["ansible_port"] != 22 {
+		t.Fatalf("unexpected web1 vars: %v", web)
+	}
+	if web["fqdn"] != "debian-12.prod.example.com" {
+		t.Fatalf("unexpected fqdn: %v", web["fqdn"])
+	}
+	if _, ok := web["missing"]; ok {
+		t.Fatalf("null results must not be set")
+	}
+	if _, ok := web["broken"]; ok {
+		t.Fatalf("failing expressions must be skipped")
+	}
+	db := inv.Hosts["db1"].Variables
+	if db["ansible_user"] != "Administrator" || db["ansible_port"] != 2222 {
+		t.Fatalf("unexpected db1 vars: %v", db)
+	}
+	if g := inv.Groups["custom_port"]; g == nil || len(g.Hosts) != 1 || g.Hosts[0] != "db1" {
+		t.Fatalf("groups must see composed vars: %s", groupHosts(inv))
+	}
+
+	if len(inv.Hosts["web1"].Sensitive) != 0 {
+		t.Fatalf("composed vars wrongly marked sensitive: %v", inv.Hosts["web1"].Sensitive)
+	}
+
+	r = compile(t, "strict: true\ncompose:\n  broken: ip + undefined\n")
+	if err := r.Apply(fixture()); err == nil {
+		t.Fatal("expected error in strict mode")
+	}
+}
+
+func TestApplyComposeSensitive(t *testing.T) {
+	r := compile(t, `
+compose:
+  db_url: format("postgres://admin:{}@db", password)
+  token_set: defined(api.token)
+  user: lower(distro)
+`)
+	inv := fixture()
+	inv.AddGroup(&inventory.Group{Name: "debian", Variables: map[string]any{"password": "s3cret"}, Sensitive: map[string]bool{"password": true}})
+	inv.Hosts["web1"].Variables["api"] = map[string]any{"token": "t"}
+	inv.Hosts["web1"].Sensitive = map[string]bool{"api": true}
+	if err := r.Apply(inv); err != nil {
+		t.Fatal(err)
+	}
+	s := inv.Hosts["web1"].Sensitive
+	if !s["db_url"] || !s["token_set"] || s["user"] {
+		t.Fatalf("unexpected sensitive vars: %v", s)
+	}
+	inv.Redact(inventory.MaskSensitive, nil)
+	if inv.Hosts["web1"].Variables["db_url"] != inventory.Mask {
+		t.Fatalf("composed secret not redacted: %v", inv.Hosts["web1"].Variables["db_url"])
+	}
+}
@@ -0,0 +1,298 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+type node interface {
+	eval(vars map[string]any) (any, error)
+}
+
+type literalNode struct{ v any }
+
+func (n *literalNode) eval(map[string]any) (any, error) { return n.v, nil }
+
+type identNode struct{ name string }
+
+func (n *identNode) eval(vars map[string]any) (any, error) {
+	v, _ := lookup(vars, n.name)
+	return v, nil
+}
+
+type definedNode struct{ name string }
+
+func (n *definedNode) eval(vars map[string]any) (any, error) {
+	_, ok := lookup(vars, n.name)
+	return ok, nil
+}
+
+type listNode struct{ items []node }
+
+func (n *listNode) eval(vars map[string]any) (any, error) {
+	out := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+type notNode struct{ x node }
+
+func (n *notNode) eval(vars map[string]any) (any, error) {
+	v, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(v), nil
+}
+
+type negNode struct{ x node }
+
+func (n *negNode) eval(vars map[string]any) (any, error) {
+	v, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := toFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate %s", describe(v))
+	}
+	return -f, nil
+}
+
+type logicalNode struct {
+	or          bool
+	left, right node
+}
+
+func (n *logicalNode) eval(vars map[string]any) (any, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(l) == n.or {
+		return n.or, nil
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(r), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(vars map[string]any) (any, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		return contains(r, l)
+	case "not in":
+		ok, err := contains(r, l)
+		return !ok, err
+	}
+	// ordering comparisons are false when either side is undefined
+	if l == nil || r == nil {
+		return false, nil
+	}
+	c, err := compare(l, r)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type matchNode struct {
+	negate      bool
+	left, right node
+	re          *regexp.Regexp
+}
+
+func (n *matchNode) eval(vars map[string]any) (any, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	re := n.re
+	if re == nil {
+		r, err := n.right.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("regular expression must be a string, got %s", describe(r))
+		}
+		if re, err = regexp.Compile(s); err != nil {
+			return nil, err
+		}
+	}
+	if l == nil {
+		return n.negate, nil
+	}
+	return re.MatchString(toString(l)) != n.negate, nil
+}
+
+// truthy reports whether v counts as true.
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() > 0
+	}
+	return true
+}
+
+// equal compares two values, treating numbers of different Go types as
+// equal when their values are.
+func equal(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders two numbers or two strings.
+func compare(a, b any) (int, error) {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1, nil
+			case fa > fb:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return strings.Compare(sa, sb), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", describe(a), describe(b))
+}
+
+// contains implements "in": membership for lists, substrings for strings
+// and keys for maps. An undefined collection contains nothing.
+func contains(coll, v any) (bool, error) {
+	switch c := coll.(type) {
+	case nil:
+		return false, nil
+	case string:
+		if v == nil {
+			return false, nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot look for %s in a string", describe(v))
+		}
+		return strings.Contains(c, s), nil
+	case []any:
+		for _, item := range c {
+			if equal(item, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]any:
+		s, ok := v.(string)
+		if !ok {
+			return false, nil
+		}
+		_, found := c[s]
+		return found, nil
+	}
+	return false, fmt.Errorf("cannot look for a value in %s", describe(coll))
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+// toString renders a value as text for regular expression matches.
+func toString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func describe(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("boolean %v", v)
+	case []any:
+		return "list"
+	case map[string]any:
+		return "map"
+	}
+	if _, ok := toFloat(v); ok {
+		return fmt.Sprintf("number %v", v)
+	}
+	return fmt.Sprintf("%T", v)
+}
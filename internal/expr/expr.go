@@ -0,0 +1,142 @@
+// Package expr implements the small expression language used to select and
+// group hosts by their variables, e.g.
+//
+//	os == "linux" && region in ["eu-1", "eu-2"]
+//	defined(backup) || hostname =~ "^db-"
+//
+// Identifiers name variables; dotted identifiers such as tags.env descend
+// into nested maps and undefined variables evaluate to null. Literals are
+// strings in single or double quotes, numbers, true, false, null and lists
+// in square brackets.
+//
+// Operators, from lowest to highest precedence:
+//
+//	|| or                     either side is true
+//	&& and                    both sides are true
+//	! not                     negation
+//	== != < <= > >=           comparison of numbers, strings and booleans
+//	=~ !~                     regular expression (RE2) match and non-match
+//	in, not in                list membership, substring or map key
+//	+                         addition of numbers, concatenation of lists
+//	                          and of anything else as text
+//
+// defined(name) reports whether a variable is set at all. The functions
+// lower(s), upper(s), trim(s), strip_cidr(s), replace(s, old, new),
+// default(x, fallback), str(x), int(x) and format(template, args...) with
+// "{}" or "{0}" placeholders transform values; string functions pass null
+// through so default() can fill it in.
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Expr is a parsed expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse parses src into an expression.
+func Parse(src string) (*Expr, error) {
+	p := &parser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	root, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source the expression was parsed from.
+func (e *Expr) String() string { return e.src }
+
+// Vars returns the sorted names of the variables the expression refers to,
+// including those only tested with defined(). Dotted names are returned as
+// written.
+func (e *Expr) Vars() []string {
+	seen := make(map[string]bool)
+	var walk func(n node)
+	walk = func(n node) {
+		switch n := n.(type) {
+		case *identNode:
+			seen[n.name] = true
+		case *definedNode:
+			seen[n.name] = true
+		case *listNode:
+			for _, item := range n.items {
+				walk(item)
+			}
+		case *notNode:
+			walk(n.x)
+		case *negNode:
+			walk(n.x)
+		case *logicalNode:
+			walk(n.left)
+			walk(n.right)
+		case *compareNode:
+			walk(n.left)
+			walk(n.right)
+		case *matchNode:
+			walk(n.left)
+			walk(n.right)
+		case *callNode:
+			for _, a := range n.args {
+				walk(a)
+			}
+		case *addNode:
+			walk(n.left)
+			walk(n.right)
+		}
+	}
+	walk(e.root)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Eval evaluates the expression against vars and returns its value.
+func (e *Expr) Eval(vars map[string]any) (any, error) {
+	v, err := e.root.eval(vars)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating %q: %w", e.src, err)
+	}
+	return v, nil
+}
+
+// Match evaluates the expression against vars and reports whether the
+// result is true: null, false, zero, empty strings, lists and maps are
+// false, everything else is true.
+func (e *Expr) Match(vars map[string]any) (bool, error) {
+	v, err := e.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+// lookup resolves a dotted variable name in vars.
+func lookup(vars map[string]any, name string) (any, bool) {
+	if v, ok := vars[name]; ok {
+		return v, true
+	}
+	parts := strings.Split(name, ".")
+	var cur any = vars
+	for _, p := range parts {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[p]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
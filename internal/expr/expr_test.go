@@ -0,0 +1,204 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testVars() map[string]any {
+	return map[string]any{
+		"os":       "linux",
+		"region":   "eu-1",
+		"cpus":     float64(4),
+		"memory":   8,
+		"backup":   false,
+		"hostname": "db-01",
+		"roles":    []any{"db", "backup"},
+		"tags":     map[string]any{"env": "staging", "team": "ops"},
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		src  string
+		want bool
+	}{
+		{`os == "linux"`, true},
+		{`os != 'linux'`, false},
+		{`os == "linux" && region in ["eu-1", "eu-2"]`, true},
+		{`os == "windows" || region not in ["eu-1"]`, false},
+		{`cpus > 2 and memory >= 8`, true},
+		{`cpus < 4`, false},
+		{`cpus <= 4 && memory == 8.0`, true},
+		{`-cpus < 0`, true},
+		{`hostname =~ "^db-\d+$"`, true},
+		{`hostname !~ "^web"`, true},
+		{`hostname > "a"`, true},
+		{`tags.env == "staging"`, true},
+		{`"ops" in tags.team`, true},
+		{`"env" in tags`, true},
+		{`"db" in roles`, true},
+		{`defined(backup)`, true},
+		{`defined(tags.owner)`, false},
+		{`!defined(missing) && missing == null`, true},
+		{`not backup`, true},
+		{`missing > 1`, false},
+		{`missing =~ "x"`, false},
+		{`region in missing`, false},
+		{`(os == "linux" || os == "bsd") && !(cpus > 8)`, true},
+		{`roles`, true},
+		{`tags.owner`, false},
+		{`true == (1 == 1)`, true},
+	}
+	vars := testVars()
+	for _, tc := range cases {
+		e, err := Parse(tc.src)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.src, err)
+		}
+		got, err := e.Match(vars)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.src, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	e, err := Parse(`tags.env`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := e.Eval(testVars()); v != "staging" {
+		t.Fatalf("unexpected value %v", v)
+	}
+	e, _ = Parse(`['a', "b\"c", 1]`)
+	v, _ := e.Eval(nil)
+	if l, ok := v.([]any); !ok || len(l) != 3 || l[1] != `b"c` || l[2] != float64(1) {
+		t.Fatalf("unexpected value %#v", v)
+	}
+}
+
+func TestVars(t *testing.T) {
+	e, err := Parse(`format("{}@{}", lower(user), tags.env) + "x" == host || defined(backup) && !(port in [22, alt_port])`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(e.Vars(), ",")
+	if want := "alt_port,backup,host,port,tags.env,user"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`os ==`,
+		`os == "linux`,
+		`(os == "linux"`,
+		`os $ 1`,
+		`hostname =~ "("`,
+		`hostname =~ 1`,
+		`defined("x")`,
+		`nosuchfunc(x)`,
+		`os == "a" "b"`,
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("%s: expected error", src)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, src := range []string{
+		`os > 1`,
+		`cpus in "abc"`,
+		`"x" in cpus`,
+		`-os`,
+	} {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if _, err := e.Match(testVars()); err == nil {
+			t.Errorf("%s: expected error", src)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	vars := map[string]any{
+		"name":    "Web-01 ",
+		"ip":      "10.0.0.5/24",
+		"port":    "2222",
+		"index":   float64(3),
+		"domain":  "example.com",
+		"admins":  []any{"alice"},
+		"missing": nil,
+	}
+	cases := []struct {
+		src  string
+		want any
+	}{
+		{`lower(trim(name))`, "web-01"},
+		{`upper("a")`, "A"},
+		{`strip_cidr(ip)`, "10.0.0.5"},
+		{`strip_cidr(undefined)`, nil},
+		{`default(ansible_user, "admin")`, "admin"},
+		{`default(lower(undefined), "x")`, "x"},
+		{`default(domain, "x")`, "example.com"},
+		{`replace(domain, ".", "_")`, "example_com"},
+		{`format("{}.{}", lower(trim(name)), domain)`, "web-01.example.com"},
+		{`format("{1}-{0}-{{x}}", "a", index)`, "3-a-{x}"},
+		{`"web-" + index`, "web-3"},
+		{`index + 1`, float64(4)},
+		{`admins + ["bob"]`, []any{"alice", "bob"}},
+		{`int(port)`, 2222},
+		{`int(index) + 0.5`, 3.5},
+		{`str(index)`, "3"},
+		{`str(missing)`, nil},
+		{`lower(domain) == "example.com"`, true},
+	}
+	for _, tc := range cases {
+		e, err := Parse(tc.src)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.src, err)
+		}
+		got, err := e.Eval(vars)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.src, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %#v, want %#v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	for _, src := range []string{
+		`lower(1)`,
+		`int("abc")`,
+		`format("{}")`,
+		`format("{x}", 1)`,
+		`format("{}", missing)`,
+		`format(1)`,
+		`missing + "x"`,
+		`replace(name, 1, "x")`,
+	} {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if _, err := e.Eval(map[string]any{"name": "a"}); err == nil {
+			t.Errorf("%s: expected error", src)
+		}
+	}
+	for _, src := range []string{`lower()`, `default(1)`, `replace(a, b)`} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("%s: expected error", src)
+		}
+	}
+}
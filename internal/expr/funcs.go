@@ -0,0 +1,198 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// function is a built-in function. It receives its evaluated arguments.
+type function struct {
+	minArgs, maxArgs int
+	call             func(args []any) (any, error)
+}
+
+// functions lists the built-ins besides defined().
+var functions = map[string]function{
+	"lower":      {1, 1, stringFunc(strings.ToLower)},
+	"upper":      {1, 1, stringFunc(strings.ToUpper)},
+	"trim":       {1, 1, stringFunc(strings.TrimSpace)},
+	"strip_cidr": {1, 1, stringFunc(stripCIDR)},
+	"default":    {2, 2, defaultFunc},
+	"replace":    {3, 3, replaceFunc},
+	"format":     {1, -1, formatFunc},
+	"str":        {1, 1, strFunc},
+	"int":        {1, 1, intFunc},
+}
+
+// stringFunc lifts a string transformation into a function. Null stays
+// null so that undefined variables can be passed through default().
+func stringFunc(f func(string) string) func([]any) (any, error) {
+	return func(args []any) (any, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %s", describe(args[0]))
+		}
+		return f(s), nil
+	}
+}
+
+func stripCIDR(s string) string {
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// defaultFunc returns its second argument when the first is null.
+func defaultFunc(args []any) (any, error) {
+	if args[0] == nil {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+func replaceFunc(args []any) (any, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	var s [3]string
+	for i, a := range args {
+		v, ok := a.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected strings, got %s", describe(a))
+		}
+		s[i] = v
+	}
+	return strings.ReplaceAll(s[0], s[1], s[2]), nil
+}
+
+// formatFunc fills the "{}" placeholders of its first argument with the
+// remaining arguments in order; "{0}", "{1}", ... refer to them by index
+// and "{{" and "}}" produce literal braces.
+func formatFunc(args []any) (any, error) {
+	tmpl, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a string template, got %s", describe(args[0]))
+	}
+	params := args[1:]
+	var b strings.Builder
+	next := 0
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		switch {
+		case c == '{' && strings.HasPrefix(tmpl[i:], "{{"), c == '}' && strings.HasPrefix(tmpl[i:], "}}"):
+			b.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed placeholder in %q", tmpl)
+			}
+			idx := next
+			if ref := tmpl[i+1 : i+end]; ref != "" {
+				n, err := strconv.Atoi(ref)
+				if err != nil {
+					return nil, fmt.Errorf("invalid placeholder {%s}", ref)
+				}
+				idx = n
+			} else {
+				next++
+			}
+			if idx < 0 || idx >= len(params) {
+				return nil, fmt.Errorf("missing argument %d for %q", idx, tmpl)
+			}
+			if params[idx] == nil {
+				return nil, fmt.Errorf("argument %d for %q is null", idx, tmpl)
+			}
+			b.WriteString(toString(params[idx]))
+			i += end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// strFunc renders a value as text, keeping null.
+func strFunc(args []any) (any, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	return toString(args[0]), nil
+}
+
+// intFunc converts numbers and numeric strings to integers.
+func intFunc(args []any) (any, error) {
+	switch x := args[0].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(x))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", x)
+		}
+		return n, nil
+	}
+	if f, ok := toFloat(args[0]); ok {
+		return int(math.Trunc(f)), nil
+	}
+	return nil, fmt.Errorf("cannot convert %s", describe(args[0]))
+}
+
+type callNode struct {
+	name string
+	fn   function
+	args []node
+}
+
+func (n *callNode) eval(vars map[string]any) (any, error) {
+	args := make([]any, 0, len(n.args))
+	for _, a := range n.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	v, err := n.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", n.name, err)
+	}
+	return v, nil
+}
+
+type addNode struct {
+	left, right node
+}
+
+// eval adds numbers and concatenates lists; anything else is joined as
+// text, so "web-" + index yields "web-3".
+func (n *addNode) eval(vars map[string]any) (any, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, fmt.Errorf("cannot add %s and %s", describe(l), describe(r))
+	}
+	if fl, ok := toFloat(l); ok {
+		if fr, ok := toFloat(r); ok {
+			return fl + fr, nil
+		}
+	}
+	if ll, ok := l.([]any); ok {
+		if rl, ok := r.([]any); ok {
+			return append(append([]any{}, ll...), rl...), nil
+		}
+	}
+	return toString(l) + toString(r), nil
+}
@@ -0,0 +1,374 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+// operators lists the operator tokens, longest first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")", "[", "]", ",", "-", "+"}
+
+func (p *parser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			str, n, err := lexString(s[i:])
+			if err != nil {
+				return fmt.Errorf("%v at offset %d", err, i)
+			}
+			p.tokens = append(p.tokens, token{tokString, str, i})
+			i += n
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokNumber, s[i:j], i})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(s) && (isIdentStart(s[j]) || s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokIdent, s[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			p.tokens = append(p.tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	p.tokens = append(p.tokens, token{tokEOF, "", len(s)})
+	return nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// lexString reads a quoted string at the start of s and returns its value
+// and length. The escapes \\, \', \", \n and \t are understood, any other
+// backslash is kept as it is.
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '\\', '\'', '"':
+				b.WriteByte(s[i])
+			default:
+				// keep unknown escapes such as \d for regular expressions
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token when it is one of the given operators or
+// keywords.
+func (p *parser) accept(words ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return "", false
+	}
+	for _, w := range words {
+		if t.text == w {
+			p.pos++
+			return w, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
+
+func (p *parser) parse() (node, error) {
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return n, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{or: true, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x: x}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "=~", "!~", "in")
+	if !ok {
+		if t := p.peek(); t.kind == tokIdent && t.text == "not" && p.tokens[p.pos+1].text == "in" {
+			p.pos += 2
+			op, ok = "not in", true
+		}
+	}
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if op == "=~" || op == "!~" {
+		return newMatchNode(op == "!~", left, right)
+	}
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseSum() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("+"); !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &addNode{left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.accept("-"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return &literalNode{v: t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return &literalNode{v: f}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{v: true}, nil
+		case "false":
+			return &literalNode{v: false}, nil
+		case "null", "none":
+			return &literalNode{v: nil}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+		return &identNode{name: t.text}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items: items}, nil
+		}
+	}
+	if t.kind != tokEOF {
+		p.pos--
+	}
+	return nil, p.unexpected()
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	args, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+	if name.text == "defined" {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("defined() takes one variable at offset %d", name.pos)
+		}
+		id, ok := args[0].(*identNode)
+		if !ok {
+			return nil, fmt.Errorf("defined() takes a variable name at offset %d", name.pos)
+		}
+		return &definedNode{name: id.name}, nil
+	}
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at offset %d", name.text, name.pos)
+	}
+	if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+		return nil, fmt.Errorf("wrong number of arguments for %s() at offset %d", name.text, name.pos)
+	}
+	return &callNode{name: name.text, fn: fn, args: args}, nil
+}
+
+// parseList parses comma separated expressions up to the closing token.
+func (p *parser) parseList(end string) ([]node, error) {
+	var items []node
+	if _, ok := p.accept(end); ok {
+		return items, nil
+	}
+	for {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+		if _, ok := p.accept(end); ok {
+			return items, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newMatchNode(negate bool, left, right node) (node, error) {
+	n := &matchNode{negate: negate, left: left, right: right}
+	if lit, ok := right.(*literalNode); ok {
+		s, ok := lit.v.(string)
+		if !ok {
+			return nil, fmt.Errorf("regular expression must be a string")
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		n.re = re
+	}
+	return n, nil
+}
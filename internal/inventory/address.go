@@ -0,0 +1,144 @@
+package inventory
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// AddressFamily selects which kind of IP address is preferred when a host
+// has several candidates.
+type AddressFamily int
+
+const (
+	// AnyFamily takes the first candidate.
+	AnyFamily AddressFamily = iota
+	// IPv4 prefers IPv4 addresses.
+	IPv4
+	// IPv6 prefers IPv6 addresses.
+	IPv6
+)
+
+// ParseAddressFamily converts "any", "ipv4" or "ipv6" into an
+// AddressFamily.
+func ParseAddressFamily(s string) (AddressFamily, error) {
+	switch strings.ToLower(s) {
+	case "", "any":
+		return AnyFamily, nil
+	case "ipv4":
+		return IPv4, nil
+	case "ipv6":
+		return IPv6, nil
+	default:
+		return AnyFamily, fmt.Errorf("unknown address family %q: use any, ipv4 or ipv6", s)
+	}
+}
+
+// String returns the name accepted by ParseAddressFamily.
+func (f AddressFamily) String() string {
+	switch f {
+	case IPv4:
+		return "ipv4"
+	case IPv6:
+		return "ipv6"
+	default:
+		return "any"
+	}
+}
+
+// AddressMapping describes how the connection address of a host is derived
+// from its variables.
+type AddressMapping struct {
+	// Sources are the variables holding candidate addresses, in order of
+	// preference. A variable may hold a single address or a list.
+	Sources []string
+	// Family is the preferred address family. Candidates of another family
+	// are only used when none of the preferred family exists.
+	Family AddressFamily
+	// Target is the variable receiving the address. It is left alone when a
+	// host already defines it.
+	Target string
+	// StripCIDR removes a "/prefix" suffix from the address.
+	StripCIDR bool
+	// KeepSources keeps the source variables instead of removing them.
+	KeepSources bool
+}
+
+// DefaultAddressMapping maps the ip variable written by most configurations
+// to ansible_host without its CIDR suffix.
+func DefaultAddressMapping() AddressMapping {
+	return AddressMapping{
+		Sources:   []string{"ip"},
+		Target:    "ansible_host",
+		StripCIDR: true,
+	}
+}
+
+// MapAddresses sets the target variable of every host according to m. The
+// target is sensitive when one of the sources it was derived from is.
+func (inv *Inventory) MapAddresses(m AddressMapping) {
+	if m.Target == "" {
+		return
+	}
+	for _, h := range inv.Hosts {
+		var candidates []any
+		sensitive := false
+		for _, src := range m.Sources {
+			v, ok := h.Variables[src]
+			if !ok {
+				continue
+			}
+			if list, ok := v.([]any); ok {
+				candidates = append(candidates, list...)
+			} else {
+				candidates = append(candidates, v)
+			}
+			sensitive = sensitive || h.Sensitive[src]
+			if !m.KeepSources && src != m.Target {
+				delete(h.Variables, src)
+				delete(h.Sensitive, src)
+			}
+		}
+		if _, set := h.Variables[m.Target]; set || len(candidates) == 0 {
+			continue
+		}
+		h.Variables[m.Target] = m.pick(candidates)
+		if sensitive {
+			h.Sensitive = AddSensitive(h.Sensitive, map[string]bool{m.Target: true})
+		}
+	}
+}
+
+// pick returns the first candidate of the preferred family, or the first
+// candidate if there is none.
+func (m AddressMapping) pick(candidates []any) any {
+	for i, c := range candidates {
+		if s, ok := c.(string); ok && m.StripCIDR {
+			candidates[i] = StripCIDR(s)
+		}
+	}
+	if m.Family != AnyFamily {
+		for _, c := range candidates {
+			s, ok := c.(string)
+			if !ok {
+				continue
+			}
+			ip := net.ParseIP(StripCIDR(s))
+			if ip == nil {
+				continue
+			}
+			if (ip.To4() != nil) == (m.Family == IPv4) {
+				return s
+			}
+		}
+	}
+	return candidates[0]
+}
+
+// StripCIDR removes a "/prefix" suffix from an address.
+func StripCIDR(addr string) string {
+	if i := strings.IndexByte(addr, '/'); i >= 0 {
+		return addr[:i]
+	}
+	return addr
+}
@@ -0,0 +1,93 @@
+package inventory
+
+import "testing"
+
+func TestStripCIDR(t *testing.T) {
+	if StripCIDR("1.2.3.4/32") != "1.2.3.4" {
+		t.Fatalf("StripCIDR failed")
+	}
+	if StripCIDR("1.2.3.4") != "1.2.3.4" {
+		t.Fatalf("StripCIDR modified plain ip")
+	}
+}
+
+func TestMapAddressesDefault(t *testing.T) {
+	inv := New()
+	inv.AddHost(&Host{Name: "a", Variables: map[string]any{"ip": "10.0.0.1/24"}})
+	inv.AddHost(&Host{Name: "b", Variables: map[string]any{"ip": "10.0.0.2", "ansible_host": "b.example.com"}})
+	inv.AddHost(&Host{Name: "c", Variables: map[string]any{"os": "linux"}})
+	inv.MapAddresses(DefaultAddressMapping())
+
+	if v := inv.Hosts["a"].Variables; v["ansible_host"] != "10.0.0.1" || v["ip"] != nil {
+		t.Fatalf("unexpected vars for a: %v", v)
+	}
+	if v := inv.Hosts["b"].Variables; v["ansible_host"] != "b.example.com" || v["ip"] != nil {
+		t.Fatalf("explicit ansible_host must win: %v", v)
+	}
+	if _, ok := inv.Hosts["c"].Variables["ansible_host"]; ok {
+		t.Fatalf("host without address got one")
+	}
+}
+
+func TestMapAddressesSensitive(t *testing.T) {
+	inv := New()
+	inv.AddHost(&Host{Name: "a", Variables: map[string]any{"ip": "10.0.0.1"}, Sensitive: map[string]bool{"ip": true}})
+	inv.MapAddresses(DefaultAddressMapping())
+	if s := inv.Hosts["a"].Sensitive; !s["ansible_host"] || s["ip"] {
+		t.Fatalf("sensitivity should move to the target: %v", s)
+	}
+}
+
+func TestMapAddressesConfigured(t *testing.T) {
+	vars := map[string]any{
+		"mgmt_address": nil,
+		"private_ip":   []any{"fd00::5/64", "10.1.0.5/16"},
+		"ipv4":         "192.0.2.7",
+	}
+	cases := []struct {
+		m    AddressMapping
+		want any
+	}{
+		{AddressMapping{Sources: []string{"private_ip", "ipv4"}, Target: "ansible_host", StripCIDR: true}, "fd00::5"},
+		{AddressMapping{Sources: []string{"private_ip", "ipv4"}, Target: "ansible_host", Family: IPv4, StripCIDR: true}, "10.1.0.5"},
+		{AddressMapping{Sources: []string{"ipv4", "private_ip"}, Target: "ansible_host", Family: IPv6}, "fd00::5/64"},
+		{AddressMapping{Sources: []string{"ipv4"}, Target: "ansible_host", Family: IPv6}, "192.0.2.7"},
+		{AddressMapping{Sources: []string{"ipv4"}, Target: "ansible_ssh_host"}, "192.0.2.7"},
+	}
+	for i, tc := range cases {
+		inv := New()
+		inv.AddHost(&Host{Name: "h", Variables: copyMap(vars)})
+		inv.MapAddresses(tc.m)
+		got := inv.Hosts["h"].Variables[tc.m.Target]
+		if got != tc.want {
+			t.Errorf("case %d: got %v, want %v", i, got, tc.want)
+		}
+		for _, src := range tc.m.Sources {
+			if _, ok := inv.Hosts["h"].Variables[src]; ok {
+				t.Errorf("case %d: source %s not removed", i, src)
+			}
+		}
+	}
+
+	inv := New()
+	inv.AddHost(&Host{Name: "h", Variables: copyMap(vars)})
+	inv.MapAddresses(AddressMapping{Sources: []string{"ipv4"}, Target: "ansible_host", KeepSources: true})
+	if inv.Hosts["h"].Variables["ipv4"] != "192.0.2.7" {
+		t.Fatalf("source removed despite KeepSources")
+	}
+}
+
+func TestParseAddressFamily(t *testing.T) {
+	for _, s := range []string{"", "any", "ipv4", "IPv6"} {
+		f, err := ParseAddressFamily(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if s != "" && f.String() != map[string]string{"any": "any", "ipv4": "ipv4", "IPv6": "ipv6"}[s] {
+			t.Fatalf("%s: unexpected family %v", s, f)
+		}
+	}
+	if _, err := ParseAddressFamily("ipx"); err == nil {
+		t.Fatal("expected error")
+	}
+}
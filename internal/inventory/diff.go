@@ -0,0 +1,201 @@
+package inventory
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Diff describes how one inventory differs from another: hosts and groups
+// that were added or removed, changes of group membership and hierarchy,
+// and variable changes of hosts, groups and the inventory itself.
+type Diff struct {
+	AddedHosts    []string      `json:"added_hosts,omitempty"`
+	RemovedHosts  []string      `json:"removed_hosts,omitempty"`
+	AddedGroups   []string      `json:"added_groups,omitempty"`
+	RemovedGroups []string      `json:"removed_groups,omitempty"`
+	Groups        []GroupChange `json:"groups,omitempty"`
+	Hosts         []HostChange  `json:"hosts,omitempty"`
+	Vars          []VarChange   `json:"vars,omitempty"`
+}
+
+// GroupChange lists the changes of a group present in both inventories, or
+// of a group that was added or removed, relative to an empty group.
+type GroupChange struct {
+	Name            string      `json:"name"`
+	AddedHosts      []string    `json:"added_hosts,omitempty"`
+	RemovedHosts    []string    `json:"removed_hosts,omitempty"`
+	AddedChildren   []string    `json:"added_children,omitempty"`
+	RemovedChildren []string    `json:"removed_children,omitempty"`
+	Vars            []VarChange `json:"vars,omitempty"`
+}
+
+// HostChange lists the changes of a host present in both inventories, or of
+// a host that was added or removed, relative to an empty host.
+type HostChange struct {
+	Name          string      `json:"name"`
+	AddedGroups   []string    `json:"added_groups,omitempty"`
+	RemovedGroups []string    `json:"removed_groups,omitempty"`
+	Enabled       *bool       `json:"enabled,omitempty"`
+	Vars          []VarChange `json:"vars,omitempty"`
+}
+
+// Kinds of variable changes.
+const (
+	VarAdded   = "added"
+	VarRemoved = "removed"
+	VarChanged = "changed"
+)
+
+// VarChange is a variable that was added, removed or changed its value.
+// Sensitive is set when either inventory marks the variable as sensitive.
+type VarChange struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Old       any    `json:"old,omitempty"`
+	New       any    `json:"new,omitempty"`
+	Sensitive bool   `json:"sensitive,omitempty"`
+}
+
+// Empty reports whether the inventories are equal.
+func (d *Diff) Empty() bool {
+	return len(d.AddedHosts) == 0 && len(d.RemovedHosts) == 0 &&
+		len(d.AddedGroups) == 0 && len(d.RemovedGroups) == 0 &&
+		len(d.Groups) == 0 && len(d.Hosts) == 0 && len(d.Vars) == 0
+}
+
+// Compare returns the changes that turn old into inv. Group membership is
+// compared by the hosts and children groups list directly, variables by
+// the values set on the host, group or inventory itself. Entries are sorted
+// by name.
+func (inv *Inventory) Compare(old *Inventory) *Diff {
+	d := &Diff{
+		Vars: diffVars(old.Vars, inv.Vars, old.Sensitive, inv.Sensitive),
+	}
+
+	for _, name := range sortedNames(unionKeys(old.Groups, inv.Groups)) {
+		og, ng := old.Groups[name], inv.Groups[name]
+		switch {
+		case og == nil:
+			d.AddedGroups = append(d.AddedGroups, name)
+			og = &Group{}
+		case ng == nil:
+			d.RemovedGroups = append(d.RemovedGroups, name)
+			ng = &Group{}
+		}
+		c := GroupChange{
+			Name:            name,
+			AddedHosts:      missing(ng.Hosts, og.Hosts),
+			RemovedHosts:    missing(og.Hosts, ng.Hosts),
+			AddedChildren:   missing(ng.Children, og.Children),
+			RemovedChildren: missing(og.Children, ng.Children),
+			Vars:            diffVars(og.Variables, ng.Variables, og.Sensitive, ng.Sensitive),
+		}
+		if len(c.AddedHosts)+len(c.RemovedHosts)+len(c.AddedChildren)+len(c.RemovedChildren)+len(c.Vars) > 0 {
+			d.Groups = append(d.Groups, c)
+		}
+	}
+
+	for _, name := range sortedNames(unionKeys(old.Hosts, inv.Hosts)) {
+		oh, nh := old.Hosts[name], inv.Hosts[name]
+		switch {
+		case oh == nil:
+			d.AddedHosts = append(d.AddedHosts, name)
+			oh = &Host{Enabled: nh.Enabled}
+		case nh == nil:
+			d.RemovedHosts = append(d.RemovedHosts, name)
+			nh = &Host{Enabled: oh.Enabled}
+		}
+		c := HostChange{
+			Name:          name,
+			AddedGroups:   missing(nh.Groups, oh.Groups),
+			RemovedGroups: missing(oh.Groups, nh.Groups),
+			Vars:          diffVars(oh.Variables, nh.Variables, oh.Sensitive, nh.Sensitive),
+		}
+		if oh.Enabled != nh.Enabled {
+			enabled := nh.Enabled
+			c.Enabled = &enabled
+		}
+		if len(c.AddedGroups)+len(c.RemovedGroups)+len(c.Vars) > 0 || c.Enabled != nil {
+			d.Hosts = append(d.Hosts, c)
+		}
+	}
+	return d
+}
+
+// Redact masks or drops the values of sensitive variable changes according
+// to mode, see Inventory.Redact.
+func (d *Diff) Redact(mode SensitiveMode) {
+	if mode == KeepSensitive {
+		return
+	}
+	redact := func(changes []VarChange) []VarChange {
+		out := changes[:0]
+		for _, c := range changes {
+			if c.Sensitive {
+				if mode == OmitSensitive {
+					continue
+				}
+				if c.Old != nil {
+					c.Old = Mask
+				}
+				if c.New != nil {
+					c.New = Mask
+				}
+			}
+			out = append(out, c)
+		}
+		return out
+	}
+	d.Vars = redact(d.Vars)
+	for i := range d.Groups {
+		d.Groups[i].Vars = redact(d.Groups[i].Vars)
+	}
+	for i := range d.Hosts {
+		d.Hosts[i].Vars = redact(d.Hosts[i].Vars)
+	}
+}
+
+// diffVars compares two variable maps.
+func diffVars(old, cur map[string]any, oldSensitive, curSensitive map[string]bool) []VarChange {
+	var out []VarChange
+	for _, k := range sortedNames(unionKeys(old, cur)) {
+		ov, inOld := old[k]
+		nv, inNew := cur[k]
+		c := VarChange{Name: k, Old: ov, New: nv, Sensitive: oldSensitive[k] || curSensitive[k]}
+		switch {
+		case !inOld:
+			c.Kind = VarAdded
+		case !inNew:
+			c.Kind = VarRemoved
+		case !reflect.DeepEqual(ov, nv):
+			c.Kind = VarChanged
+		default:
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// missing returns the names in a that are not in b, sorted.
+func missing(a, b []string) []string {
+	var out []string
+	for _, s := range a {
+		if !contains(b, s) && !contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func unionKeys[T any](a, b map[string]T) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
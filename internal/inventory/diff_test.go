@@ -0,0 +1,103 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func diffFixture() (*Inventory, *Inventory) {
+	old := New()
+	old.AddVars(map[string]any{"env": "staging", "gone": true})
+	old.AddGroup(&Group{Name: "prod", Children: []string{"web"}})
+	old.AddGroup(&Group{Name: "web", Variables: map[string]any{"port": 80.0}})
+	old.AddGroup(&Group{Name: "legacy"})
+	old.AddHost(&Host{Name: "web1", Groups: []string{"web"}, Variables: map[string]any{"os": "debian", "pw": "a"}, Sensitive: map[string]bool{"pw": true}, Enabled: true})
+	old.AddHost(&Host{Name: "web2", Groups: []string{"web"}, Enabled: true})
+	old.AddHost(&Host{Name: "old1", Groups: []string{"legacy"}, Enabled: true})
+
+	cur := New()
+	cur.AddVars(map[string]any{"env": "prod"})
+	cur.AddGroup(&Group{Name: "prod"})
+	cur.AddGroup(&Group{Name: "web", Variables: map[string]any{"port": 8080.0}})
+	cur.AddGroup(&Group{Name: "cache"})
+	cur.AddHost(&Host{Name: "web1", Groups: []string{"web", "cache"}, Variables: map[string]any{"os": "ubuntu", "pw": "b"}, Sensitive: map[string]bool{"pw": true}, Enabled: false})
+	cur.AddHost(&Host{Name: "web2", Groups: []string{"web"}, Enabled: true})
+	cur.AddHost(&Host{Name: "new1", Enabled: true, Variables: map[string]any{"a": 1.0}})
+	return old, cur
+}
+
+func TestCompare(t *testing.T) {
+	old, cur := diffFixture()
+	d := cur.Compare(old)
+
+	if !reflect.DeepEqual(d.AddedHosts, []string{"new1"}) || !reflect.DeepEqual(d.RemovedHosts, []string{"old1"}) {
+		t.Errorf("hosts: +%v -%v", d.AddedHosts, d.RemovedHosts)
+	}
+	if !reflect.DeepEqual(d.AddedGroups, []string{"cache"}) || !reflect.DeepEqual(d.RemovedGroups, []string{"legacy"}) {
+		t.Errorf("groups: +%v -%v", d.AddedGroups, d.RemovedGroups)
+	}
+	wantVars := []VarChange{
+		{Name: "env", Kind: VarChanged, Old: "staging", New: "prod"},
+		{Name: "gone", Kind: VarRemoved, Old: true},
+	}
+	if !reflect.DeepEqual(d.Vars, wantVars) {
+		t.Errorf("inventory vars: %+v", d.Vars)
+	}
+
+	groups := make(map[string]GroupChange)
+	for _, g := range d.Groups {
+		groups[g.Name] = g
+	}
+	if g := groups["prod"]; !reflect.DeepEqual(g.RemovedChildren, []string{"web"}) {
+		t.Errorf("prod: %+v", g)
+	}
+	if g := groups["web"]; len(g.Vars) != 1 || g.Vars[0].Old != 80.0 || g.Vars[0].New != 8080.0 || len(g.AddedHosts)+len(g.RemovedHosts) != 0 {
+		t.Errorf("web: %+v", g)
+	}
+	if g := groups["cache"]; !reflect.DeepEqual(g.AddedHosts, []string{"web1"}) {
+		t.Errorf("cache: %+v", g)
+	}
+	if g := groups["legacy"]; !reflect.DeepEqual(g.RemovedHosts, []string{"old1"}) {
+		t.Errorf("legacy: %+v", g)
+	}
+
+	hosts := make(map[string]HostChange)
+	for _, h := range d.Hosts {
+		hosts[h.Name] = h
+	}
+	if _, ok := hosts["web2"]; ok {
+		t.Errorf("unchanged host web2 reported")
+	}
+	h := hosts["web1"]
+	if !reflect.DeepEqual(h.AddedGroups, []string{"cache"}) || h.Enabled == nil || *h.Enabled || len(h.Vars) != 2 || !h.Vars[1].Sensitive {
+		t.Errorf("web1: %+v", h)
+	}
+	if h := hosts["new1"]; len(h.Vars) != 1 || h.Vars[0].Kind != VarAdded || h.Enabled != nil {
+		t.Errorf("new1: %+v", h)
+	}
+
+	if d.Empty() || !cur.Compare(cur).Empty() {
+		t.Fatal("Empty mismatch")
+	}
+}
+
+func TestDiffRedact(t *testing.T) {
+	old, cur := diffFixture()
+	d := cur.Compare(old)
+	d.Redact(MaskSensitive)
+	for _, h := range d.Hosts {
+		if h.Name == "web1" {
+			if pw := h.Vars[1]; pw.Old != Mask || pw.New != Mask {
+				t.Fatalf("sensitive change not masked: %+v", pw)
+			}
+		}
+	}
+
+	d = cur.Compare(old)
+	d.Redact(OmitSensitive)
+	for _, h := range d.Hosts {
+		if h.Name == "web1" && len(h.Vars) != 1 {
+			t.Fatalf("sensitive change not omitted: %+v", h.Vars)
+		}
+	}
+}
@@ -1,38 +1,71 @@
 package inventory
 
+import "fmt"
+
 // Inventory holds hosts and groups parsed from Terraform state.
 // It loosely mirrors the capabilities of the ansible/ansible provider.
+// Playbooks lists the playbook runs of the state in the order they were
+// read. Sensitive names the inventory variables Terraform marked as
+// sensitive.
 type Inventory struct {
-	Hosts  map[string]*Host
-	Groups map[string]*Group
-	Vars   map[string]string
+	Hosts     map[string]*Host
+	Groups    map[string]*Group
+	Vars      map[string]any
+	Sensitive map[string]bool `json:",omitempty"`
+	Playbooks []*Playbook     `json:",omitempty"`
 }
 
 // AddVars merges the provided variables with any existing inventory level
 // variables.
-func (inv *Inventory) AddVars(v map[string]string) {
+func (inv *Inventory) AddVars(v map[string]any) {
 	if inv.Vars == nil {
-		inv.Vars = make(map[string]string)
+		inv.Vars = make(map[string]any)
 	}
 	for k, val := range v {
 		inv.Vars[k] = val
 	}
 }
 
+// MarkSensitive records the named inventory variables as sensitive.
+func (inv *Inventory) MarkSensitive(names ...string) {
+	if len(names) == 0 {
+		return
+	}
+	if inv.Sensitive == nil {
+		inv.Sensitive = make(map[string]bool)
+	}
+	for _, n := range names {
+		inv.Sensitive[n] = true
+	}
+}
+
+// Host is a single inventory host. Variables keep the type they had in the
+// Terraform state, so numbers, booleans, lists and maps survive untouched.
+//
+// Metadata records where the host came from (resource address, module,
+// index, provider and input); MetadataToVars exposes it as host variables.
+// Sensitive names the variables Terraform marked as sensitive.
 type Host struct {
 	Name      string
-	Variables map[string]string
+	Variables map[string]any
 	Groups    []string
 	Enabled   bool
 	Metadata  map[string]string
+	Sensitive map[string]bool `json:",omitempty"`
 }
 
+// Group is an inventory group with its own typed variables. Declared is set
+// for groups added through AddGroup and unset for groups that only exist
+// because a host or another group refers to them. Sensitive names the
+// variables Terraform marked as sensitive.
 type Group struct {
 	Name      string
-	Variables map[string]string
+	Variables map[string]any
 	Children  []string
 	Hosts     []string
 	Parents   []string
+	Declared  bool
+	Sensitive map[string]bool `json:",omitempty"`
 }
 
 // New creates an empty Inventory structure.
@@ -40,7 +73,7 @@ func New() *Inventory {
 	return &Inventory{
 		Hosts:  make(map[string]*Host),
 		Groups: make(map[string]*Group),
-		Vars:   make(map[string]string),
+		Vars:   make(map[string]any),
 	}
 }
 
@@ -64,12 +97,13 @@ func (inv *Inventory) AddHost(h *Host) {
 				existing.Metadata[k] = v
 			}
 		}
+		existing.Sensitive = AddSensitive(existing.Sensitive, h.Sensitive)
 		if h.Enabled {
 			existing.Enabled = h.Enabled
 		}
 	} else {
 		if h.Variables == nil {
-			h.Variables = make(map[string]string)
+			h.Variables = make(map[string]any)
 		}
 		if h.Metadata == nil {
 			h.Metadata = make(map[string]string)
@@ -86,24 +120,21 @@ func (inv *Inventory) AddHost(h *Host) {
 	}
 }
 
-// AddGroup adds or updates a group.
+// AddGroup adds or updates a group and marks it as declared. Children and
+// Parents are kept bidirectional: a child lists the group among its parents
+// and a parent lists it among its children, creating either side as needed.
 func (inv *Inventory) AddGroup(g *Group) {
 	grp := inv.ensureGroup(g.Name)
+	grp.Declared = true
 	for k, v := range g.Variables {
 		grp.Variables[k] = v
 	}
+	grp.Sensitive = AddSensitive(grp.Sensitive, g.Sensitive)
 	for _, child := range g.Children {
-		if !contains(grp.Children, child) {
-			grp.Children = append(grp.Children, child)
-		}
-	}
-	for _, child := range g.Children {
-		inv.ensureGroup(child)
+		inv.link(g.Name, child)
 	}
 	for _, p := range g.Parents {
-		if !contains(grp.Parents, p) {
-			grp.Parents = append(grp.Parents, p)
-		}
+		inv.link(p, g.Name)
 	}
 	for _, hname := range g.Hosts {
 		if !contains(grp.Hosts, hname) {
@@ -121,18 +152,56 @@ func (inv *Inventory) AddGroup(g *Group) {
 	}
 }
 
+// link records parent as a parent of child and child as a child of parent.
+func (inv *Inventory) link(parent, child string) {
+	p := inv.ensureGroup(parent)
+	c := inv.ensureGroup(child)
+	if !contains(p.Children, child) {
+		p.Children = append(p.Children, child)
+	}
+	if !contains(c.Parents, parent) {
+		c.Parents = append(c.Parents, parent)
+	}
+}
+
+// copyGroup adds g like AddGroup but keeps the declared state of g and of
+// any existing group with the same name instead of declaring it.
+func (inv *Inventory) copyGroup(g *Group) {
+	declared := g.Declared
+	if existing, ok := inv.Groups[g.Name]; ok && existing.Declared {
+		declared = true
+	}
+	inv.AddGroup(g)
+	inv.Groups[g.Name].Declared = declared
+}
+
 func (inv *Inventory) ensureGroup(name string) *Group {
 	if g, ok := inv.Groups[name]; ok {
 		return g
 	}
 	g := &Group{
 		Name:      name,
-		Variables: make(map[string]string),
+		Variables: make(map[string]any),
 	}
 	inv.Groups[name] = g
 	return g
 }
 
+// AddSensitive adds the names src marks as sensitive to dst, creating dst
+// when needed, and returns dst.
+func AddSensitive(dst, src map[string]bool) map[string]bool {
+	for k, v := range src {
+		if !v {
+			continue
+		}
+		if dst == nil {
+			dst = make(map[string]bool)
+		}
+		dst[k] = true
+	}
+	return dst
+}
+
 func contains(slice []string, s string) bool {
 	for _, v := range slice {
 		if v == s {
@@ -142,11 +211,11 @@ func contains(slice []string, s string) bool {
 	return false
 }
 
-func copyMap(src map[string]string) map[string]string {
+func copyMap[T any](src map[string]T) map[string]T {
 	if src == nil {
 		return nil
 	}
-	m := make(map[string]string, len(src))
+	m := make(map[string]T, len(src))
 	for k, v := range src {
 		m[k] = v
 	}
@@ -167,6 +236,7 @@ func (inv *Inventory) CopyFiltered(hosts, groups []string) *Inventory {
 
 	out := New()
 	out.AddVars(inv.Vars)
+	out.MarkSensitive(sortedNames(inv.Sensitive)...)
 
 	for name, h := range inv.Hosts {
 		if len(hostSet) > 0 && !hostSet[name] {
@@ -188,6 +258,7 @@ func (inv *Inventory) CopyFiltered(hosts, groups []string) *Inventory {
 			Name:      h.Name,
 			Variables: copyMap(h.Variables),
 			Metadata:  copyMap(h.Metadata),
+			Sensitive: copyMap(h.Sensitive),
 			Groups:    append([]string(nil), h.Groups...),
 			Enabled:   h.Enabled,
 		}
@@ -201,12 +272,126 @@ func (inv *Inventory) CopyFiltered(hosts, groups []string) *Inventory {
 		ng := &Group{
 			Name:      g.Name,
 			Variables: copyMap(g.Variables),
-			Children:  append([]string(nil), g.Children...),
+			Children:  filterNames(g.Children, groupSet),
 			Hosts:     append([]string(nil), g.Hosts...),
-			Parents:   append([]string(nil), g.Parents...),
+			Parents:   filterNames(g.Parents, groupSet),
+			Declared:  g.Declared,
+			Sensitive: copyMap(g.Sensitive),
 		}
-		out.AddGroup(ng)
+		out.copyGroup(ng)
 	}
+	out.Playbooks = copyPlaybooks(inv.Playbooks, out.Hosts)
+
+	return out
+}
 
+// Select returns a copy of the inventory holding only the hosts for which
+// keep returns true. Groups are kept when they contain a selected host
+// directly or through a descendant group; their host, child and parent lists
+// are cut down to what is kept.
+func (inv *Inventory) Select(keep func(h *Host) bool) *Inventory {
+	hostSet := make(map[string]bool)
+	for name, h := range inv.Hosts {
+		if keep(h) {
+			hostSet[name] = true
+		}
+	}
+	parents := inv.parentIndex()
+	groupSet := make(map[string]bool)
+	var mark func(string)
+	mark = func(name string) {
+		if _, ok := inv.Groups[name]; !ok || groupSet[name] {
+			return
+		}
+		groupSet[name] = true
+		for _, p := range parents[name] {
+			mark(p)
+		}
+	}
+	for name := range hostSet {
+		for _, g := range inv.Hosts[name].Groups {
+			mark(g)
+		}
+	}
+
+	out := New()
+	out.AddVars(inv.Vars)
+	out.MarkSensitive(sortedNames(inv.Sensitive)...)
+	for name := range hostSet {
+		h := inv.Hosts[name]
+		out.AddHost(&Host{
+			Name:      h.Name,
+			Variables: copyMap(h.Variables),
+			Metadata:  copyMap(h.Metadata),
+			Sensitive: copyMap(h.Sensitive),
+			Groups:    selectNames(h.Groups, groupSet),
+			Enabled:   h.Enabled,
+		})
+	}
+	for name := range groupSet {
+		g := inv.Groups[name]
+		out.copyGroup(&Group{
+			Name:      g.Name,
+			Variables: copyMap(g.Variables),
+			Children:  selectNames(g.Children, groupSet),
+			Hosts:     selectNames(g.Hosts, hostSet),
+			Parents:   selectNames(g.Parents, groupSet),
+			Declared:  g.Declared,
+			Sensitive: copyMap(g.Sensitive),
+		})
+	}
+	out.Playbooks = copyPlaybooks(inv.Playbooks, out.Hosts)
+	return out
+}
+
+// Where returns a copy of the inventory holding only the hosts whose
+// resolved variables (see HostVars) satisfy match, for instance the Match
+// method of a parsed expression. The first error returned by match aborts
+// the selection.
+func (inv *Inventory) Where(match func(vars map[string]any) (bool, error)) (*Inventory, error) {
+	var err error
+	out := inv.Select(func(h *Host) bool {
+		if err != nil {
+			return false
+		}
+		ok, e := match(inv.HostVars(h.Name))
+		if e != nil {
+			err = fmt.Errorf("host %s: %w", h.Name, e)
+		}
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MetadataToVars copies every host's metadata into its variables, prefixing
+// each key with prefix (e.g. "tf_address" for prefix "tf_").
+func (inv *Inventory) MetadataToVars(prefix string) {
+	for _, h := range inv.Hosts {
+		for k, v := range h.Metadata {
+			h.Variables[prefix+k] = v
+		}
+	}
+}
+
+// filterNames returns the names contained in keep, or a copy of all names
+// when keep is empty.
+func filterNames(names []string, keep map[string]bool) []string {
+	if len(keep) == 0 {
+		return append([]string(nil), names...)
+	}
+	return selectNames(names, keep)
+}
+
+// selectNames returns the names contained in keep.
+func selectNames(names []string, keep map[string]bool) []string {
+	var out []string
+	for _, n := range names {
+		if keep[n] {
+			out = append(out, n)
+		}
+	}
 	return out
 }
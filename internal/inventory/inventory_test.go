@@ -1,11 +1,14 @@
 package inventory
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestAddVars(t *testing.T) {
 	inv := New()
-	inv.AddVars(map[string]string{"a": "1"})
-	inv.AddVars(map[string]string{"b": "2", "a": "x"})
+	inv.AddVars(map[string]any{"a": "1"})
+	inv.AddVars(map[string]any{"b": "2", "a": "x"})
 	if inv.Vars["a"] != "x" || inv.Vars["b"] != "2" {
 		t.Fatalf("vars not merged correctly: %#v", inv.Vars)
 	}
@@ -13,8 +16,8 @@ func TestAddVars(t *testing.T) {
 
 func TestAddHostNewAndMerge(t *testing.T) {
 	inv := New()
-	inv.AddHost(&Host{Name: "h1", Groups: []string{"g1"}, Variables: map[string]string{"ip": "1"}})
-	inv.AddHost(&Host{Name: "h1", Groups: []string{"g2"}, Variables: map[string]string{"os": "linux"}, Metadata: map[string]string{"role": "db"}, Enabled: true})
+	inv.AddHost(&Host{Name: "h1", Groups: []string{"g1"}, Variables: map[string]any{"ip": "1"}})
+	inv.AddHost(&Host{Name: "h1", Groups: []string{"g2"}, Variables: map[string]any{"os": "linux"}, Metadata: map[string]string{"role": "db"}, Enabled: true})
 
 	h, ok := inv.Hosts["h1"]
 	if !ok {
@@ -39,8 +42,8 @@ func TestAddHostNewAndMerge(t *testing.T) {
 
 func TestAddGroupNewAndMerge(t *testing.T) {
 	inv := New()
-	inv.AddGroup(&Group{Name: "web", Variables: map[string]string{"tier": "fe"}, Hosts: []string{"h1"}, Children: []string{"child"}, Parents: []string{"parent"}})
-	inv.AddGroup(&Group{Name: "web", Variables: map[string]string{"env": "prod"}, Hosts: []string{"h2"}, Children: []string{"child2"}})
+	inv.AddGroup(&Group{Name: "web", Variables: map[string]any{"tier": "fe"}, Hosts: []string{"h1"}, Children: []string{"child"}, Parents: []string{"parent"}})
+	inv.AddGroup(&Group{Name: "web", Variables: map[string]any{"env": "prod"}, Hosts: []string{"h2"}, Children: []string{"child2"}})
 
 	g, ok := inv.Groups["web"]
 	if !ok {
@@ -71,7 +74,7 @@ func TestAddGroupNewAndMerge(t *testing.T) {
 
 func TestCopyFiltered(t *testing.T) {
 	inv := New()
-	inv.AddVars(map[string]string{"env": "test"})
+	inv.AddVars(map[string]any{"env": "test"})
 	inv.AddHost(&Host{Name: "h1", Groups: []string{"web"}})
 	inv.AddHost(&Host{Name: "h2", Groups: []string{"db"}})
 	inv.AddGroup(&Group{Name: "web", Hosts: []string{"h1"}})
@@ -103,7 +106,7 @@ func TestAddHostNilMaps(t *testing.T) {
 	if h.Variables == nil || h.Metadata == nil {
 		t.Fatalf("nil maps after AddHost: vars=%v meta=%v", h.Variables, h.Metadata)
 	}
-	inv.AddHost(&Host{Name: "x1", Variables: map[string]string{"os": "linux"}})
+	inv.AddHost(&Host{Name: "x1", Variables: map[string]any{"os": "linux"}})
 	if h.Variables["os"] != "linux" {
 		t.Fatalf("variable merge failed: %#v", h.Variables)
 	}
@@ -142,3 +145,175 @@ func TestAddHostInvalidName(t *testing.T) {
 		t.Fatalf("expected deduped empty host in group, got %v", inv.Groups["web"].Hosts)
 	}
 }
+
+func TestHostVarsPrecedence(t *testing.T) {
+	inv := New()
+	inv.AddVars(map[string]any{"a": "inv", "b": "inv", "c": "inv", "d": "inv"})
+	inv.AddGroup(&Group{Name: "prod", Variables: map[string]any{"b": "prod", "c": "prod", "d": "prod"}, Children: []string{"web"}})
+	inv.AddGroup(&Group{Name: "web", Variables: map[string]any{"c": "web", "d": "web"}})
+	inv.AddHost(&Host{Name: "h1", Groups: []string{"web"}, Variables: map[string]any{"d": "host"}})
+
+	vars := inv.HostVars("h1")
+	want := map[string]string{"a": "inv", "b": "prod", "c": "web", "d": "host"}
+	for k, v := range want {
+		if vars[k] != v {
+			t.Fatalf("var %s = %v, want %s (all: %#v)", k, vars[k], v, vars)
+		}
+	}
+	groups := inv.HostGroups("h1")
+	if len(groups) != 2 || groups[0] != "prod" || groups[1] != "web" {
+		t.Fatalf("unexpected host groups: %v", groups)
+	}
+	if inv.HostVars("missing") != nil {
+		t.Fatalf("expected nil vars for unknown host")
+	}
+}
+
+func TestHostGroupsCycle(t *testing.T) {
+	inv := New()
+	inv.AddGroup(&Group{Name: "a", Children: []string{"b"}})
+	inv.AddGroup(&Group{Name: "b", Children: []string{"a"}})
+	inv.AddHost(&Host{Name: "h1", Groups: []string{"a"}})
+	if groups := inv.HostGroups("h1"); len(groups) != 2 {
+		t.Fatalf("unexpected groups on cycle: %v", groups)
+	}
+}
+
+func TestMetadataToVars(t *testing.T) {
+	inv := New()
+	inv.AddHost(&Host{Name: "h1", Metadata: map[string]string{"address": "ansible_host.h1"}})
+	inv.MetadataToVars("tf_")
+	if inv.Hosts["h1"].Variables["tf_address"] != "ansible_host.h1" {
+		t.Fatalf("metadata not exposed: %#v", inv.Hosts["h1"].Variables)
+	}
+}
+
+func TestGroupRelationsBidirectional(t *testing.T) {
+	inv := New()
+	// a -> b -> c -> d, declared through a mix of children and parents
+	inv.AddGroup(&Group{Name: "a", Children: []string{"b"}})
+	inv.AddGroup(&Group{Name: "c", Parents: []string{"b"}})
+	inv.AddGroup(&Group{Name: "d", Parents: []string{"c"}})
+	// diamond: top -> left, right -> bottom
+	inv.AddGroup(&Group{Name: "top", Children: []string{"left"}})
+	inv.AddGroup(&Group{Name: "right", Parents: []string{"top"}, Children: []string{"bottom"}})
+	inv.AddGroup(&Group{Name: "bottom", Parents: []string{"left"}})
+
+	cases := []struct {
+		name     string
+		children []string
+		parents  []string
+	}{
+		{"a", []string{"b"}, nil},
+		{"b", []string{"c"}, []string{"a"}},
+		{"c", []string{"d"}, []string{"b"}},
+		{"d", nil, []string{"c"}},
+		{"top", []string{"left", "right"}, nil},
+		{"left", []string{"bottom"}, []string{"top"}},
+		{"right", []string{"bottom"}, []string{"top"}},
+		{"bottom", nil, []string{"left", "right"}},
+	}
+	for _, tc := range cases {
+		g := inv.Groups[tc.name]
+		if g == nil {
+			t.Fatalf("group %s missing", tc.name)
+		}
+		if got := sortedCopy(g.Children); !equalNames(got, tc.children) {
+			t.Errorf("%s children = %v, want %v", tc.name, got, tc.children)
+		}
+		if got := sortedCopy(g.Parents); !equalNames(got, tc.parents) {
+			t.Errorf("%s parents = %v, want %v", tc.name, got, tc.parents)
+		}
+	}
+	if inv.Groups["b"].Declared {
+		t.Fatalf("group only referenced by relations must stay undeclared")
+	}
+	inv.AddHost(&Host{Name: "h1", Groups: []string{"d"}})
+	if got := inv.HostGroups("h1"); !equalNames(got, []string{"a", "b", "c", "d"}) {
+		t.Fatalf("unexpected ancestors of d: %v", got)
+	}
+}
+
+func TestCopyFilteredPrunesRelations(t *testing.T) {
+	inv := New()
+	inv.AddGroup(&Group{Name: "prod", Children: []string{"web", "db"}})
+	inv.AddHost(&Host{Name: "w1", Groups: []string{"web"}})
+	inv.AddHost(&Host{Name: "d1", Groups: []string{"db"}})
+
+	out := inv.CopyFiltered(nil, []string{"prod", "web"})
+	if _, ok := out.Groups["db"]; ok {
+		t.Fatalf("filtered group db must not be recreated")
+	}
+	if got := out.Groups["prod"].Children; len(got) != 1 || got[0] != "web" {
+		t.Fatalf("unexpected children: %v", got)
+	}
+	if got := out.Groups["web"].Parents; len(got) != 1 || got[0] != "prod" {
+		t.Fatalf("unexpected parents: %v", got)
+	}
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWhere(t *testing.T) {
+	inv := New()
+	inv.AddVars(map[string]any{"os": "linux"})
+	inv.AddGroup(&Group{Name: "win", Variables: map[string]any{"os": "windows"}})
+	inv.AddHost(&Host{Name: "l1", Variables: map[string]any{"region": "eu"}})
+	inv.AddHost(&Host{Name: "w1", Groups: []string{"win"}})
+
+	linux := func(vars map[string]any) (bool, error) { return vars["os"] == "linux", nil }
+	out, err := inv.Where(linux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Hosts) != 1 || out.Hosts["l1"] == nil || len(out.Groups) != 0 {
+		t.Fatalf("unexpected selection: %v %v", out.Hosts, out.Groups)
+	}
+	if out.Vars["os"] != "linux" {
+		t.Fatalf("inventory vars not kept")
+	}
+
+	fail := func(map[string]any) (bool, error) { return false, fmt.Errorf("boom") }
+	if _, err := inv.Where(fail); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestSensitiveCarriedAlong(t *testing.T) {
+	src := New()
+	src.AddVars(map[string]any{"api_key": "k"})
+	src.MarkSensitive("api_key")
+	src.AddGroup(&Group{Name: "db", Variables: map[string]any{"pw": "x"}, Sensitive: map[string]bool{"pw": true}})
+	src.AddHost(&Host{Name: "db1", Groups: []string{"db"}, Variables: map[string]any{"token": "t"}, Sensitive: map[string]bool{"token": true}, Enabled: true})
+
+	merged := New()
+	merged.AddHost(&Host{Name: "db1", Variables: map[string]any{"os": "linux"}, Sensitive: map[string]bool{"other": true}})
+	if err := merged.Merge(src, LastWins); err != nil {
+		t.Fatal(err)
+	}
+	if !merged.Sensitive["api_key"] || !merged.Groups["db"].Sensitive["pw"] {
+		t.Fatalf("merge lost sensitivity: %v %v", merged.Sensitive, merged.Groups["db"].Sensitive)
+	}
+	if s := merged.Hosts["db1"].Sensitive; !s["token"] || !s["other"] {
+		t.Fatalf("host sensitivity should be combined: %v", s)
+	}
+
+	for name, out := range map[string]*Inventory{
+		"CopyFiltered": merged.CopyFiltered(nil, []string{"db"}),
+		"Select":       merged.Select(func(*Host) bool { return true }),
+	} {
+		if !out.Sensitive["api_key"] || !out.Groups["db"].Sensitive["pw"] || !out.Hosts["db1"].Sensitive["token"] {
+			t.Errorf("%s lost sensitivity", name)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package inventory
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ConflictPolicy decides which value is kept when inventories being merged
+// define the same variable with different values.
+type ConflictPolicy int
+
+const (
+	// LastWins keeps the value from the inventory merged last.
+	LastWins ConflictPolicy = iota
+	// FirstWins keeps the value that was defined first.
+	FirstWins
+	// FailOnConflict aborts the merge with an error.
+	FailOnConflict
+)
+
+// ParseConflictPolicy converts "last-wins", "first-wins" or "error" into a
+// ConflictPolicy.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch s {
+	case "", "last-wins":
+		return LastWins, nil
+	case "first-wins":
+		return FirstWins, nil
+	case "error":
+		return FailOnConflict, nil
+	default:
+		return LastWins, fmt.Errorf("unknown conflict policy: %s", s)
+	}
+}
+
+func (p ConflictPolicy) String() string {
+	switch p {
+	case FirstWins:
+		return "first-wins"
+	case FailOnConflict:
+		return "error"
+	default:
+		return "last-wins"
+	}
+}
+
+// Merge adds the hosts, groups and variables of src to inv using the
+// AddHost and AddGroup semantics. Group memberships and hierarchies are
+// combined; variables defined on both sides with different values are
+// resolved according to policy. A variable stays sensitive when either side
+// marks it so. Playbook runs of src are appended.
+//
+// The same host appearing in both inventories is not a conflict by itself;
+// only its variables are checked against policy. The enabled state and the
+// metadata keys of a host follow LastWins and FirstWins like variables; with
+// FailOnConflict the host is enabled when either side enables it and its
+// metadata describes src.
+func (inv *Inventory) Merge(src *Inventory, policy ConflictPolicy) error {
+	// Merging groups creates their member hosts, so remember which hosts
+	// came from earlier inputs before touching them.
+	prior := make(map[string]bool, len(inv.Hosts))
+	for name := range inv.Hosts {
+		prior[name] = true
+	}
+
+	vars, err := mergeVars(inv.Vars, src.Vars, policy, "inventory")
+	if err != nil {
+		return err
+	}
+	inv.AddVars(vars)
+	inv.MarkSensitive(sortedNames(src.Sensitive)...)
+
+	for _, name := range sortedNames(src.Groups) {
+		g := src.Groups[name]
+		var existing map[string]any
+		if eg, ok := inv.Groups[name]; ok {
+			existing = eg.Variables
+		}
+		vars, err := mergeVars(existing, g.Variables, policy, fmt.Sprintf("group %q", name))
+		if err != nil {
+			return err
+		}
+		inv.copyGroup(&Group{
+			Name:      g.Name,
+			Variables: vars,
+			Children:  append([]string(nil), g.Children...),
+			Hosts:     append([]string(nil), g.Hosts...),
+			Parents:   append([]string(nil), g.Parents...),
+			Declared:  g.Declared,
+			Sensitive: copyMap(g.Sensitive),
+		})
+	}
+
+	for _, name := range sortedNames(src.Hosts) {
+		h := src.Hosts[name]
+		nh := &Host{
+			Name:      h.Name,
+			Variables: copyMap(h.Variables),
+			Metadata:  copyMap(h.Metadata),
+			Sensitive: copyMap(h.Sensitive),
+			Groups:    append([]string(nil), h.Groups...),
+			Enabled:   h.Enabled,
+		}
+		eh, ok := inv.Hosts[name]
+		if !ok || !prior[name] {
+			inv.AddHost(nh)
+			continue
+		}
+		vars, err := mergeVars(eh.Variables, h.Variables, policy, fmt.Sprintf("host %q", name))
+		if err != nil {
+			return err
+		}
+		nh.Variables = vars
+		enabled := eh.Enabled || h.Enabled
+		switch policy {
+		case LastWins:
+			enabled = h.Enabled
+		case FirstWins:
+			enabled = eh.Enabled
+			for k := range eh.Metadata {
+				delete(nh.Metadata, k)
+			}
+		}
+		inv.AddHost(nh)
+		inv.Hosts[name].Enabled = enabled
+	}
+	inv.Playbooks = append(inv.Playbooks, copyPlaybooks(src.Playbooks, src.Hosts)...)
+	return nil
+}
+
+// mergeVars returns the variables of src that should be written over dst.
+func mergeVars(dst, src map[string]any, policy ConflictPolicy, owner string) (map[string]any, error) {
+	out := make(map[string]any, len(src))
+	for k, v := range src {
+		old, ok := dst[k]
+		if ok && !reflect.DeepEqual(old, v) {
+			switch policy {
+			case FirstWins:
+				continue
+			case FailOnConflict:
+				return nil, fmt.Errorf("conflicting values for variable %q of %s", k, owner)
+			}
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
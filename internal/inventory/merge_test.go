@@ -0,0 +1,110 @@
+package inventory
+
+import "testing"
+
+func mergeFixtures() (*Inventory, *Inventory) {
+	a := New()
+	a.AddVars(map[string]any{"env": "prod"})
+	a.AddGroup(&Group{Name: "web", Variables: map[string]any{"tier": "fe"}})
+	a.AddHost(&Host{Name: "h1", Groups: []string{"web"}, Variables: map[string]any{"ip": "10.0.0.1", "os": "linux"}, Enabled: true})
+
+	b := New()
+	b.AddVars(map[string]any{"env": "staging", "region": "eu"})
+	b.AddGroup(&Group{Name: "web", Variables: map[string]any{"tier": "frontend"}})
+	b.AddHost(&Host{Name: "h1", Groups: []string{"db"}, Variables: map[string]any{"ip": "10.0.0.2", "os": "linux"}, Enabled: true})
+	b.AddHost(&Host{Name: "h2", Groups: []string{"web"}, Enabled: true})
+	return a, b
+}
+
+func TestMergeLastWins(t *testing.T) {
+	a, b := mergeFixtures()
+	if err := a.Merge(b, LastWins); err != nil {
+		t.Fatalf("merge error: %v", err)
+	}
+	if a.Vars["env"] != "staging" || a.Vars["region"] != "eu" {
+		t.Fatalf("inventory vars not merged: %#v", a.Vars)
+	}
+	if a.Groups["web"].Variables["tier"] != "frontend" {
+		t.Fatalf("group vars not overwritten: %#v", a.Groups["web"].Variables)
+	}
+	h1 := a.Hosts["h1"]
+	if h1.Variables["ip"] != "10.0.0.2" || !contains(h1.Groups, "web") || !contains(h1.Groups, "db") {
+		t.Fatalf("host not merged: %#v", h1)
+	}
+	if !contains(a.Groups["web"].Hosts, "h2") || !contains(a.Groups["db"].Hosts, "h1") {
+		t.Fatalf("memberships not merged: web=%v db=%v", a.Groups["web"].Hosts, a.Groups["db"].Hosts)
+	}
+}
+
+func TestMergeFirstWins(t *testing.T) {
+	a, b := mergeFixtures()
+	if err := a.Merge(b, FirstWins); err != nil {
+		t.Fatalf("merge error: %v", err)
+	}
+	if a.Vars["env"] != "prod" || a.Vars["region"] != "eu" {
+		t.Fatalf("inventory vars wrong: %#v", a.Vars)
+	}
+	if a.Groups["web"].Variables["tier"] != "fe" {
+		t.Fatalf("group var overwritten: %#v", a.Groups["web"].Variables)
+	}
+	if a.Hosts["h1"].Variables["ip"] != "10.0.0.1" {
+		t.Fatalf("host var overwritten: %#v", a.Hosts["h1"].Variables)
+	}
+}
+
+func TestMergeFirstWinsKeepsGroupedHost(t *testing.T) {
+	src := New()
+	src.AddGroup(&Group{Name: "web", Hosts: []string{"h1"}})
+	src.AddHost(&Host{Name: "h1", Groups: []string{"web"}, Metadata: map[string]string{"address": "aws_instance.h1"}, Enabled: true})
+
+	inv := New()
+	if err := inv.Merge(src, FirstWins); err != nil {
+		t.Fatalf("merge error: %v", err)
+	}
+	h := inv.Hosts["h1"]
+	if !h.Enabled || h.Metadata["address"] != "aws_instance.h1" {
+		t.Fatalf("grouped host lost its state: %#v", h)
+	}
+
+	other := New()
+	other.AddHost(&Host{Name: "h1", Metadata: map[string]string{"address": "aws_instance.other", "module": "m"}})
+	if err := inv.Merge(other, FirstWins); err != nil {
+		t.Fatalf("merge error: %v", err)
+	}
+	if !h.Enabled || h.Metadata["address"] != "aws_instance.h1" || h.Metadata["module"] != "m" {
+		t.Fatalf("first-wins not applied per field: %#v", h)
+	}
+}
+
+func TestMergeFailOnConflict(t *testing.T) {
+	a, b := mergeFixtures()
+	if err := a.Merge(b, FailOnConflict); err == nil {
+		t.Fatal("expected conflict error")
+	}
+
+	c := New()
+	c.AddHost(&Host{Name: "h3", Variables: map[string]any{"os": "linux"}})
+	d := New()
+	d.AddHost(&Host{Name: "h3", Variables: map[string]any{"os": "linux", "port": float64(22)}})
+	if err := c.Merge(d, FailOnConflict); err != nil {
+		t.Fatalf("identical values should not conflict: %v", err)
+	}
+	if c.Hosts["h3"].Variables["port"] != float64(22) {
+		t.Fatalf("new variable not merged: %#v", c.Hosts["h3"].Variables)
+	}
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	for in, want := range map[string]ConflictPolicy{"": LastWins, "last-wins": LastWins, "first-wins": FirstWins, "error": FailOnConflict} {
+		got, err := ParseConflictPolicy(in)
+		if err != nil || got != want {
+			t.Errorf("ParseConflictPolicy(%q) = %v, %v", in, got, err)
+		}
+		if in != "" && got.String() != in {
+			t.Errorf("String() = %q, want %q", got.String(), in)
+		}
+	}
+	if _, err := ParseConflictPolicy("bogus"); err == nil {
+		t.Fatal("expected error for unknown policy")
+	}
+}
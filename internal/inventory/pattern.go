@@ -0,0 +1,469 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Pattern is a parsed Ansible host pattern as accepted by
+// "ansible-playbook --limit", e.g. "web:&prod:!db*".
+//
+// Terms are separated by "," or ":" (colons inside brackets do not split).
+// A term prefixed with "&" intersects the selection, one prefixed with "!"
+// removes hosts from it. Each term is one of
+//
+//   - "all" or "*" for every host, "ungrouped" for hosts without a group,
+//   - a group or host name, optionally with shell style wildcards; a group
+//     selects its own hosts and those of all descendant groups,
+//   - "~regex", matched against the start of group and host names,
+//   - a subscript of any of the above such as "web[0]", "web[1:3]" or
+//     "web[2:]" (inclusive, zero based),
+//   - a host range such as "web[01:20]" or "db-[a:c]" when the part before
+//     the brackets matches nothing,
+//   - "@file", reading one pattern per line from file.
+type Pattern struct {
+	terms []patternTerm
+}
+
+type patternTerm struct {
+	op    byte // 0, '&' or '!'
+	expr  string
+	re    *regexp.Regexp
+	sub   *subscript
+	base  string // expr without the subscript
+	names []string
+}
+
+type subscript struct {
+	start, end int
+	single     bool
+}
+
+var subscriptPattern = regexp.MustCompile(`^(.+)\[(?:(-?[0-9]+)|([0-9]*):([0-9]*))\]$`)
+
+// ParsePattern parses an Ansible host pattern. "@file" terms are read while
+// parsing.
+func ParsePattern(s string) (*Pattern, error) {
+	raw, err := splitPattern(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pattern{}
+	for _, r := range raw {
+		t, err := parseTerm(r)
+		if err != nil {
+			return nil, err
+		}
+		p.terms = append(p.terms, t)
+	}
+	return p, nil
+}
+
+// splitPattern splits s into its terms and replaces "@file" terms by the
+// patterns listed in the file.
+func splitPattern(s string) ([]string, error) {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		for _, term := range splitColons(strings.TrimSpace(part)) {
+			if !strings.HasPrefix(term, "@") {
+				out = append(out, term)
+				continue
+			}
+			data, err := os.ReadFile(term[1:])
+			if err != nil {
+				return nil, fmt.Errorf("reading limit file: %w", err)
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				line = strings.TrimSpace(line)
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				terms, err := splitPattern(line)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, terms...)
+			}
+		}
+	}
+	return out, nil
+}
+
+// splitColons splits a term list on colons outside of brackets. IP
+// addresses are kept whole.
+func splitColons(s string) []string {
+	if s == "" {
+		return nil
+	}
+	if net.ParseIP(strings.TrimLeft(s, "&!")) != nil {
+		return []string{s}
+	}
+	var out []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+			}
+		case ':':
+			if depth == 0 {
+				if t := strings.TrimSpace(s[start:i]); t != "" {
+					out = append(out, t)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if t := strings.TrimSpace(s[start:]); t != "" {
+		out = append(out, t)
+	}
+	return out
+}
+
+func parseTerm(s string) (patternTerm, error) {
+	t := patternTerm{expr: s}
+	if s[0] == '&' || s[0] == '!' {
+		t.op = s[0]
+		s = s[1:]
+	}
+	if s == "" {
+		return t, fmt.Errorf("empty host pattern in %q", t.expr)
+	}
+	if s[0] == '~' {
+		re, err := regexp.Compile("^(?:" + s[1:] + ")")
+		if err != nil {
+			return t, fmt.Errorf("invalid host pattern %q: %w", t.expr, err)
+		}
+		t.re = re
+		return t, nil
+	}
+	t.base = s
+	if m := subscriptPattern.FindStringSubmatch(s); m != nil {
+		t.base = m[1]
+		sub := &subscript{end: -1}
+		if m[2] != "" {
+			sub.start, _ = strconv.Atoi(m[2])
+			sub.single = true
+		} else {
+			if m[3] != "" {
+				sub.start, _ = strconv.Atoi(m[3])
+			}
+			if m[4] != "" {
+				sub.end, _ = strconv.Atoi(m[4])
+			}
+		}
+		t.sub = sub
+	}
+	names, err := ExpandHostRange(s)
+	if err != nil {
+		return t, fmt.Errorf("invalid host pattern %q: %w", t.expr, err)
+	}
+	t.names = names
+	re, err := regexp.Compile(globToRegexp(t.base))
+	if err != nil {
+		return t, fmt.Errorf("invalid host pattern %q: %w", t.expr, err)
+	}
+	t.re = re
+	return t, nil
+}
+
+// MatchHosts returns the sorted names of the hosts selected by p. Plain
+// terms are applied first, then intersections and finally exclusions, the
+// way Ansible orders them; with no plain term the selection starts from all
+// hosts.
+func (inv *Inventory) MatchHosts(p *Pattern) []string {
+	var plain, and, not []patternTerm
+	for _, t := range p.terms {
+		switch t.op {
+		case '&':
+			and = append(and, t)
+		case '!':
+			not = append(not, t)
+		default:
+			plain = append(plain, t)
+		}
+	}
+
+	selected := make(map[string]bool)
+	if len(plain) == 0 {
+		for name := range inv.Hosts {
+			selected[name] = true
+		}
+	}
+	for _, t := range plain {
+		for _, h := range inv.matchTerm(t) {
+			selected[h] = true
+		}
+	}
+	for _, t := range and {
+		keep := make(map[string]bool)
+		for _, h := range inv.matchTerm(t) {
+			keep[h] = true
+		}
+		for h := range selected {
+			if !keep[h] {
+				delete(selected, h)
+			}
+		}
+	}
+	for _, t := range not {
+		for _, h := range inv.matchTerm(t) {
+			delete(selected, h)
+		}
+	}
+	return sortedNames(selected)
+}
+
+// Limit returns a copy of the inventory restricted to the hosts matched by
+// the Ansible host pattern, see Pattern and Select.
+func (inv *Inventory) Limit(pattern string) (*Inventory, error) {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool)
+	for _, h := range inv.MatchHosts(p) {
+		keep[h] = true
+	}
+	return inv.Select(func(h *Host) bool { return keep[h.Name] }), nil
+}
+
+// matchTerm returns the hosts matched by a single term, ordered so that
+// subscripts are stable: the hosts of each matching group (see groupHosts)
+// followed by matching host names.
+func (inv *Inventory) matchTerm(t patternTerm) []string {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(hosts ...string) {
+		for _, h := range hosts {
+			if !seen[h] {
+				seen[h] = true
+				out = append(out, h)
+			}
+		}
+	}
+
+	var groups []string
+	for _, g := range sortedNames(inv.Groups) {
+		if t.re.MatchString(g) {
+			groups = append(groups, g)
+		}
+	}
+	if t.base != "" && len(groups) == 0 {
+		switch {
+		case t.base == "all" || t.base == "*":
+			add(sortedNames(inv.Hosts)...)
+		case t.base == "ungrouped":
+			for _, name := range sortedNames(inv.Hosts) {
+				if len(inv.Hosts[name].Groups) == 0 {
+					add(name)
+				}
+			}
+		}
+	}
+	for _, g := range groups {
+		add(inv.groupHosts(g)...)
+	}
+	if len(groups) == 0 || t.base == "" || strings.ContainsAny(t.base, ".?*[") {
+		for _, name := range sortedNames(inv.Hosts) {
+			if t.re.MatchString(name) {
+				add(name)
+			}
+		}
+	}
+
+	if len(out) == 0 && t.names != nil {
+		for _, name := range t.names {
+			if _, ok := inv.Hosts[name]; ok {
+				add(name)
+			}
+		}
+		return out
+	}
+	if t.sub != nil {
+		return t.sub.apply(out)
+	}
+	return out
+}
+
+// apply cuts hosts down to the subscript. Out of range subscripts select
+// nothing.
+func (s *subscript) apply(hosts []string) []string {
+	start := s.start
+	if start < 0 {
+		start += len(hosts)
+	}
+	if start < 0 || start >= len(hosts) {
+		return nil
+	}
+	if s.single {
+		return hosts[start : start+1]
+	}
+	end := s.end
+	if end < 0 || end >= len(hosts) {
+		end = len(hosts) - 1
+	}
+	if end < start {
+		return nil
+	}
+	return hosts[start : end+1]
+}
+
+// groupHosts returns the hosts of the named group followed by those of its
+// descendants, walking children in name order.
+func (inv *Inventory) groupHosts(name string) []string {
+	var out []string
+	seenHost := make(map[string]bool)
+	seenGroup := make(map[string]bool)
+	var walk func(string)
+	walk = func(name string) {
+		g, ok := inv.Groups[name]
+		if !ok || seenGroup[name] {
+			return
+		}
+		seenGroup[name] = true
+		for _, h := range sortedCopy(g.Hosts) {
+			if !seenHost[h] {
+				seenHost[h] = true
+				out = append(out, h)
+			}
+		}
+		for _, c := range sortedCopy(g.Children) {
+			walk(c)
+		}
+	}
+	walk(name)
+	return out
+}
+
+// globToRegexp translates a shell style wildcard pattern into an anchored
+// regular expression, like Python's fnmatch.translate.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^(?:")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			j := strings.IndexByte(glob[i+1:], ']')
+			if j < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += j + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(")$")
+	return b.String()
+}
+
+var hostRangePattern = regexp.MustCompile(`\[([0-9]+|[a-zA-Z]):([0-9]+|[a-zA-Z])(?::([0-9]+))?\]`)
+
+// MaxHostRange is the largest number of host names ExpandHostRange
+// returns, so that a pattern such as "web[0:999999999]" fails instead of
+// exhausting memory.
+const MaxHostRange = 10000
+
+// ExpandHostRange expands Ansible inventory host ranges such as
+// "web[01:20]", "node[0:10:2]" or "db-[a:c].example.com". Numeric ranges
+// keep the zero padding of their start. Nil is returned when s contains no
+// range; an error when it expands to more than MaxHostRange names.
+func ExpandHostRange(s string) ([]string, error) {
+	out, err := expandHostRange(s, MaxHostRange)
+	if errors.Is(err, errRangeTooLarge) {
+		return nil, fmt.Errorf("host range %s expands to more than %d names", s, MaxHostRange)
+	}
+	return out, err
+}
+
+var errRangeTooLarge = errors.New("host range too large")
+
+// expandHostRange expands s into at most limit names.
+func expandHostRange(s string, limit int) ([]string, error) {
+	loc := hostRangePattern.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, nil
+	}
+	prefix, suffix := s[:loc[0]], s[loc[1]:]
+	from, to := s[loc[2]:loc[3]], s[loc[4]:loc[5]]
+	step := 1
+	if loc[6] >= 0 {
+		var err error
+		if step, err = strconv.Atoi(s[loc[6]:loc[7]]); err != nil || step < 1 {
+			return nil, fmt.Errorf("range step %s must be a positive number", s[loc[6]:loc[7]])
+		}
+	}
+
+	var items []string
+	switch fromDigit, toDigit := isDigit(from[0]), isDigit(to[0]); {
+	case fromDigit && toDigit:
+		fromNum, errFrom := strconv.Atoi(from)
+		toNum, errTo := strconv.Atoi(to)
+		if errFrom != nil || errTo != nil {
+			return nil, errRangeTooLarge
+		}
+		if fromNum > toNum {
+			return nil, fmt.Errorf("range start %s is after end %s", from, to)
+		}
+		if (toNum-fromNum)/step >= limit {
+			return nil, errRangeTooLarge
+		}
+		format := "%d"
+		if len(from) > 1 && from[0] == '0' {
+			format = "%0" + strconv.Itoa(len(from)) + "d"
+		}
+		for i := fromNum; i <= toNum; i += step {
+			items = append(items, fmt.Sprintf(format, i))
+		}
+	case !fromDigit && !toDigit:
+		if from[0] > to[0] {
+			return nil, fmt.Errorf("range start %s is after end %s", from, to)
+		}
+		for c := int(from[0]); c <= int(to[0]); c += step {
+			items = append(items, string(rune(c)))
+		}
+		if len(items) > limit {
+			return nil, errRangeTooLarge
+		}
+	default:
+		return nil, fmt.Errorf("range %s:%s mixes numbers and letters", from, to)
+	}
+
+	rest, err := expandHostRange(suffix, limit/len(items))
+	if err != nil {
+		return nil, err
+	}
+	if rest == nil {
+		rest = []string{suffix}
+	}
+	out := make([]string, 0, len(items)*len(rest))
+	for _, item := range items {
+		for _, r := range rest {
+			out = append(out, prefix+item+r)
+		}
+	}
+	return out, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
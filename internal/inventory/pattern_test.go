@@ -0,0 +1,148 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func patternFixture() *Inventory {
+	inv := New()
+	inv.AddGroup(&Group{Name: "prod", Children: []string{"web", "db"}})
+	inv.AddGroup(&Group{Name: "staging", Children: []string{"web_staging"}})
+	for _, h := range []struct {
+		name   string
+		groups []string
+	}{
+		{"web01", []string{"web"}},
+		{"web02", []string{"web"}},
+		{"web03", []string{"web", "canary"}},
+		{"db1", []string{"db"}},
+		{"db2", []string{"db"}},
+		{"web-stg", []string{"web_staging"}},
+		{"lonely", nil},
+	} {
+		inv.AddHost(&Host{Name: h.name, Groups: h.groups, Enabled: true})
+	}
+	return inv
+}
+
+func TestMatchHosts(t *testing.T) {
+	inv := patternFixture()
+	cases := []struct {
+		pattern string
+		want    string
+	}{
+		{"all", "db1 db2 lonely web-stg web01 web02 web03"},
+		{"*", "db1 db2 lonely web-stg web01 web02 web03"},
+		{"ungrouped", "lonely"},
+		{"prod", "db1 db2 web01 web02 web03"},
+		{"web:db", "db1 db2 web01 web02 web03"},
+		{"web,db1", "db1 web01 web02 web03"},
+		{"prod:&web:!canary", "web01 web02"},
+		{"prod:!db*", "web01 web02 web03"},
+		{"!prod", "lonely web-stg"},
+		{"web*", "web-stg web01 web02 web03"},
+		{"~web0[12]", "web01 web02"},
+		{"~db|lonely", "db1 db2 lonely"},
+		{"web[0]", "web01"},
+		{"web[-1]", "web03"},
+		{"web[1:]", "web02 web03"},
+		{"prod[0:1]", "db1 db2"},
+		{"db[1:2]", "db2"},
+		{"web0[1:2]", "web01 web02"},
+		{"web[01:09]", "web02 web03"},
+		{"web[02:09].example.com:db1", "db1"},
+		{"nothing", ""},
+		{"lonely", "lonely"},
+	}
+	for _, tc := range cases {
+		p, err := ParsePattern(tc.pattern)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.pattern, err)
+		}
+		if got := strings.Join(inv.MatchHosts(p), " "); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestParsePatternFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry")
+	if err := os.WriteFile(path, []byte("db1\n\n# comment\nweb01:web02\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePattern("@" + path + ":!web02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(patternFixture().MatchHosts(p), " "); got != "db1 web01" {
+		t.Fatalf("unexpected hosts: %q", got)
+	}
+	if _, err := ParsePattern("@" + path + ".missing"); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+func TestParsePatternErrors(t *testing.T) {
+	for _, s := range []string{"~web(", "!", "web[9:1]", "web[1:c]"} {
+		if _, err := ParsePattern(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+func TestExpandHostRange(t *testing.T) {
+	got, err := ExpandHostRange("web[08:10].example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, " ") != "web08.example.com web09.example.com web10.example.com" {
+		t.Fatalf("unexpected expansion: %v", got)
+	}
+	got, _ = ExpandHostRange("db-[a:b][1:2]")
+	if strings.Join(got, " ") != "db-a1 db-a2 db-b1 db-b2" {
+		t.Fatalf("unexpected expansion: %v", got)
+	}
+	got, _ = ExpandHostRange("node[0:6:3]-[a:e:2]")
+	if strings.Join(got, " ") != "node0-a node0-c node0-e node3-a node3-c node3-e node6-a node6-c node6-e" {
+		t.Fatalf("unexpected expansion: %v", got)
+	}
+	if got, _ := ExpandHostRange("plain"); got != nil {
+		t.Fatalf("unexpected expansion: %v", got)
+	}
+	if _, err := ExpandHostRange("web[1:3:0]"); err == nil {
+		t.Fatal("expected error for zero step")
+	}
+	if got, err := ExpandHostRange("x[1:10000]"); err != nil || len(got) != MaxHostRange {
+		t.Fatalf("expected %d names, got %d (%v)", MaxHostRange, len(got), err)
+	}
+	for _, s := range []string{"x[0:1000000000]", "x[0:99999999999999999999]", "x[0:99][0:100]", "x[a:z][A:Z][0:20]"} {
+		if _, err := ExpandHostRange(s); err == nil || !strings.Contains(err.Error(), "more than") {
+			t.Errorf("%s: expected size error, got %v", s, err)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	out, err := patternFixture().Limit("web:&canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Hosts) != 1 || out.Hosts["web03"] == nil {
+		t.Fatalf("unexpected hosts: %v", sortedNames(out.Hosts))
+	}
+	if got := strings.Join(sortedNames(out.Groups), " "); got != "canary prod web" {
+		t.Fatalf("unexpected groups: %s", got)
+	}
+	if got := out.Groups["prod"].Children; len(got) != 1 || got[0] != "web" {
+		t.Fatalf("children not pruned: %v", got)
+	}
+	if got := out.Groups["web"].Hosts; len(got) != 1 || got[0] != "web03" {
+		t.Fatalf("hosts not pruned: %v", got)
+	}
+	if !out.Groups["prod"].Declared || out.Groups["web"].Declared {
+		t.Fatalf("declared state not kept")
+	}
+}
@@ -0,0 +1,51 @@
+package inventory
+
+// Playbook is a playbook run defined by an ansible_playbook resource. The
+// provider runs Playbook against Host, which it adds to Groups in a
+// temporary inventory, passing ExtraVars on the command line. Sensitive
+// names the extra vars Terraform marked as sensitive.
+type Playbook struct {
+	Playbook   string            `json:"playbook" yaml:"playbook"`
+	Host       string            `json:"host" yaml:"host"`
+	Groups     []string          `json:"groups,omitempty" yaml:"groups,omitempty"`
+	ExtraVars  map[string]any    `json:"extra_vars,omitempty" yaml:"extra_vars,omitempty"`
+	Replayable bool              `json:"replayable" yaml:"replayable"`
+	Metadata   map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Sensitive  map[string]bool   `json:"-" yaml:"-"`
+}
+
+// AddPlaybook records the playbook run and adds its host to the run's
+// groups, like the provider does at runtime. The host is created, enabled
+// and with the run's metadata, only when the inventory does not know it yet;
+// an existing host keeps its state and metadata. Add playbook runs after the
+// hosts, as a host added later cannot be disabled again.
+func (inv *Inventory) AddPlaybook(p *Playbook) {
+	inv.Playbooks = append(inv.Playbooks, p)
+	h := &Host{Name: p.Host, Groups: append([]string(nil), p.Groups...)}
+	if _, ok := inv.Hosts[p.Host]; !ok {
+		h.Enabled = true
+		h.Metadata = copyMap(p.Metadata)
+	}
+	inv.AddHost(h)
+}
+
+// copyPlaybooks returns copies of the playbook runs whose host is in
+// hosts.
+func copyPlaybooks(playbooks []*Playbook, hosts map[string]*Host) []*Playbook {
+	var out []*Playbook
+	for _, p := range playbooks {
+		if _, ok := hosts[p.Host]; !ok {
+			continue
+		}
+		out = append(out, &Playbook{
+			Playbook:   p.Playbook,
+			Host:       p.Host,
+			Groups:     append([]string(nil), p.Groups...),
+			ExtraVars:  copyMap(p.ExtraVars),
+			Replayable: p.Replayable,
+			Metadata:   copyMap(p.Metadata),
+			Sensitive:  copyMap(p.Sensitive),
+		})
+	}
+	return out
+}
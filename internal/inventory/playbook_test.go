@@ -0,0 +1,72 @@
+package inventory
+
+import "testing"
+
+func playbookFixture() *Inventory {
+	inv := New()
+	inv.AddPlaybook(&Playbook{Playbook: "site.yml", Host: "web1", Groups: []string{"web"}, ExtraVars: map[string]any{"env": "prod"}, Replayable: true})
+	inv.AddPlaybook(&Playbook{Playbook: "db.yml", Host: "db1", Groups: []string{"db"}})
+	return inv
+}
+
+func TestAddPlaybook(t *testing.T) {
+	inv := playbookFixture()
+	if len(inv.Playbooks) != 2 {
+		t.Fatalf("expected two playbook runs, got %d", len(inv.Playbooks))
+	}
+	h := inv.Hosts["web1"]
+	if h == nil || !h.Enabled || len(h.Groups) != 1 || h.Groups[0] != "web" {
+		t.Fatalf("host not added: %+v", h)
+	}
+	if g := inv.Groups["web"]; g == nil || len(g.Hosts) != 1 || g.Hosts[0] != "web1" {
+		t.Fatalf("group not added: %+v", g)
+	}
+}
+
+func TestAddPlaybookExistingHost(t *testing.T) {
+	inv := New()
+	inv.AddHost(&Host{Name: "web1", Groups: []string{"web"}, Metadata: map[string]string{"address": "ansible_host.web1"}})
+	inv.AddPlaybook(&Playbook{Playbook: "site.yml", Host: "web1", Groups: []string{"deploy"}, Metadata: map[string]string{"address": "ansible_playbook.site"}})
+
+	h := inv.Hosts["web1"]
+	if h.Enabled {
+		t.Fatal("disabled host re-enabled by playbook")
+	}
+	if h.Metadata["address"] != "ansible_host.web1" {
+		t.Fatalf("host metadata overwritten: %v", h.Metadata)
+	}
+	if len(h.Groups) != 2 || inv.Groups["deploy"] == nil || inv.Groups["deploy"].Hosts[0] != "web1" {
+		t.Fatalf("playbook groups not added: %v", h.Groups)
+	}
+}
+
+func TestPlaybooksFollowHosts(t *testing.T) {
+	inv := playbookFixture()
+
+	limited, err := inv.Limit("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limited.Playbooks) != 1 || limited.Playbooks[0].Playbook != "site.yml" {
+		t.Fatalf("unexpected playbooks after limit: %+v", limited.Playbooks)
+	}
+	limited.Playbooks[0].ExtraVars["env"] = "changed"
+	if inv.Playbooks[0].ExtraVars["env"] != "prod" {
+		t.Fatalf("selection should copy extra vars")
+	}
+
+	if got := inv.CopyFiltered(nil, []string{"db"}).Playbooks; len(got) != 1 || got[0].Host != "db1" {
+		t.Fatalf("unexpected playbooks after filtering: %+v", got)
+	}
+
+	merged := New()
+	if err := merged.Merge(inv, LastWins); err != nil {
+		t.Fatal(err)
+	}
+	if err := merged.Merge(playbookFixture(), LastWins); err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Playbooks) != 4 {
+		t.Fatalf("expected four playbook runs after merging, got %d", len(merged.Playbooks))
+	}
+}
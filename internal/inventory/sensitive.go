@@ -0,0 +1,79 @@
+package inventory
+
+import "fmt"
+
+// SensitiveMode decides how variables Terraform marked as sensitive are
+// written.
+type SensitiveMode int
+
+const (
+	// MaskSensitive replaces the value with Mask.
+	MaskSensitive SensitiveMode = iota
+	// OmitSensitive leaves the variable out.
+	OmitSensitive
+	// KeepSensitive writes the value in clear text.
+	KeepSensitive
+)
+
+// Mask is the value masked variables are given.
+const Mask = "***"
+
+// ParseSensitiveMode converts "mask", "omit" or "keep" into a
+// SensitiveMode.
+func ParseSensitiveMode(s string) (SensitiveMode, error) {
+	switch s {
+	case "", "mask":
+		return MaskSensitive, nil
+	case "omit":
+		return OmitSensitive, nil
+	case "keep":
+		return KeepSensitive, nil
+	default:
+		return MaskSensitive, fmt.Errorf("unknown sensitive mode %q: use mask, omit or keep", s)
+	}
+}
+
+func (m SensitiveMode) String() string {
+	switch m {
+	case OmitSensitive:
+		return "omit"
+	case KeepSensitive:
+		return "keep"
+	default:
+		return "mask"
+	}
+}
+
+// Redact masks or removes the sensitive host, group and inventory variables
+// and playbook extra vars according to mode. Variables for which exempt
+// returns true, such as those about to be vault-encrypted, are left alone;
+// exempt may be nil.
+func (inv *Inventory) Redact(mode SensitiveMode, exempt func(name string) bool) {
+	if mode == KeepSensitive {
+		return
+	}
+	redactVars(inv.Vars, inv.Sensitive, mode, exempt)
+	for _, g := range inv.Groups {
+		redactVars(g.Variables, g.Sensitive, mode, exempt)
+	}
+	for _, h := range inv.Hosts {
+		redactVars(h.Variables, h.Sensitive, mode, exempt)
+	}
+	for _, p := range inv.Playbooks {
+		redactVars(p.ExtraVars, p.Sensitive, mode, exempt)
+	}
+}
+
+func redactVars(vars map[string]any, sensitive map[string]bool, mode SensitiveMode, exempt func(string) bool) {
+	for k := range sensitive {
+		if _, ok := vars[k]; !ok || (exempt != nil && exempt(k)) {
+			continue
+		}
+		if mode == OmitSensitive {
+			delete(vars, k)
+			delete(sensitive, k)
+		} else {
+			vars[k] = Mask
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package inventory
+
+import "testing"
+
+func sensitiveFixture() *Inventory {
+	inv := New()
+	inv.AddVars(map[string]any{"api_key": "k", "env": "prod"})
+	inv.MarkSensitive("api_key")
+	inv.AddGroup(&Group{Name: "db", Variables: map[string]any{"pw": "x", "port": 5432}, Sensitive: map[string]bool{"pw": true}})
+	inv.AddHost(&Host{Name: "db1", Variables: map[string]any{"token": "t", "vaulted": "v", "os": "linux"}, Sensitive: map[string]bool{"token": true, "vaulted": true}})
+	inv.AddPlaybook(&Playbook{Playbook: "site.yml", Host: "db1", ExtraVars: map[string]any{"deploy_key": "d", "env": "prod"}, Sensitive: map[string]bool{"deploy_key": true}})
+	return inv
+}
+
+func TestRedact(t *testing.T) {
+	exempt := func(name string) bool { return name == "vaulted" }
+
+	inv := sensitiveFixture()
+	inv.Redact(MaskSensitive, exempt)
+	if inv.Vars["api_key"] != Mask || inv.Groups["db"].Variables["pw"] != Mask || inv.Hosts["db1"].Variables["token"] != Mask {
+		t.Fatalf("sensitive values not masked: %v %v %v", inv.Vars, inv.Groups["db"].Variables, inv.Hosts["db1"].Variables)
+	}
+	if inv.Vars["env"] != "prod" || inv.Groups["db"].Variables["port"] != 5432 || inv.Hosts["db1"].Variables["vaulted"] != "v" {
+		t.Fatalf("other values should be untouched")
+	}
+	if extra := inv.Playbooks[0].ExtraVars; extra["deploy_key"] != Mask || extra["env"] != "prod" {
+		t.Fatalf("playbook extra vars not masked: %v", extra)
+	}
+
+	inv = sensitiveFixture()
+	inv.Redact(OmitSensitive, exempt)
+	if _, ok := inv.Vars["api_key"]; ok {
+		t.Fatalf("inventory variable not omitted")
+	}
+	if _, ok := inv.Hosts["db1"].Variables["token"]; ok || inv.Hosts["db1"].Sensitive["token"] {
+		t.Fatalf("host variable not omitted")
+	}
+	if inv.Hosts["db1"].Variables["vaulted"] != "v" {
+		t.Fatalf("exempt variable should be kept")
+	}
+
+	inv = sensitiveFixture()
+	inv.Redact(KeepSensitive, nil)
+	if inv.Vars["api_key"] != "k" || inv.Hosts["db1"].Variables["token"] != "t" {
+		t.Fatalf("keep should not change values")
+	}
+}
+
+func TestParseSensitiveMode(t *testing.T) {
+	for s, want := range map[string]SensitiveMode{"": MaskSensitive, "mask": MaskSensitive, "omit": OmitSensitive, "keep": KeepSensitive} {
+		got, err := ParseSensitiveMode(s)
+		if err != nil || got != want {
+			t.Errorf("ParseSensitiveMode(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseSensitiveMode("show"); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
+
+func TestHostSensitive(t *testing.T) {
+	inv := New()
+	inv.AddVars(map[string]any{"token": "a", "region": "eu"})
+	inv.MarkSensitive("token")
+	inv.AddGroup(&Group{Name: "db", Variables: map[string]any{"password": "p"}, Sensitive: map[string]bool{"password": true}})
+	inv.AddHost(&Host{Name: "h1", Groups: []string{"db"}, Variables: map[string]any{"token": "own"}})
+
+	s := inv.HostSensitive("h1")
+	if s["token"] || !s["password"] || s["region"] {
+		t.Fatalf("unexpected sensitivity: %v", s)
+	}
+	if inv.HostSensitive("missing") != nil {
+		t.Fatal("expected nil for unknown host")
+	}
+}
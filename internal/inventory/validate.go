@@ -0,0 +1,163 @@
+package inventory
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Severity classifies a validation finding.
+type Severity string
+
+const (
+	// SeverityError marks problems that break the inventory in Ansible.
+	SeverityError Severity = "error"
+	// SeverityWarning marks suspicious but usable definitions.
+	SeverityWarning Severity = "warning"
+)
+
+// Finding codes reported by Validate.
+const (
+	CodeUndeclaredGroup    = "undeclared-group"
+	CodeGroupCycle         = "group-cycle"
+	CodeInvalidHostName    = "invalid-host-name"
+	CodeGroupNameRewritten = "group-name-rewritten"
+	CodeMissingAddress     = "missing-address"
+)
+
+// Finding is a single structural problem found by Validate.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Subject  string   `json:"subject"`
+	Message  string   `json:"message"`
+}
+
+var (
+	validHostName  = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.:@%-]*$`)
+	invalidGroupCh = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// Validate reports structural problems of the inventory: groups that are
+// referenced but never declared, cycles in the group hierarchy, host names
+// Ansible cannot use, group names Ansible will rewrite and hosts without the
+// variable address, the Target of the address mapping in use. Findings are
+// grouped by check and sorted by subject.
+func (inv *Inventory) Validate(address string) []Finding {
+	var out []Finding
+	add := func(sev Severity, code, subject, format string, args ...any) {
+		out = append(out, Finding{Severity: sev, Code: code, Subject: subject, Message: fmt.Sprintf(format, args...)})
+	}
+
+	groupNames := sortedNames(inv.Groups)
+	for _, name := range groupNames {
+		if !inv.Groups[name].Declared {
+			add(SeverityWarning, CodeUndeclaredGroup, name, "group %q is referenced but never declared", name)
+		}
+	}
+
+	for _, cycle := range inv.groupCycles() {
+		add(SeverityError, CodeGroupCycle, strings.Join(cycle, " -> "), "groups form a cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	for _, name := range groupNames {
+		if safe := SafeGroupName(name); safe != name {
+			add(SeverityWarning, CodeGroupNameRewritten, name, "Ansible will rewrite group %q to %q", name, safe)
+		}
+	}
+
+	for _, name := range sortedNames(inv.Hosts) {
+		if !validHostName.MatchString(name) {
+			add(SeverityError, CodeInvalidHostName, name, "host name %q is not a valid Ansible host name", name)
+		}
+		if _, ok := inv.Hosts[name].Variables[address]; !ok {
+			add(SeverityWarning, CodeMissingAddress, name, "host %q has no %s", name, address)
+		}
+	}
+	return out
+}
+
+// SafeGroupName returns name the way Ansible transforms invalid group
+// names: every character other than letters, digits and underscores becomes
+// an underscore.
+func SafeGroupName(name string) string {
+	return invalidGroupCh.ReplaceAllString(name, "_")
+}
+
+// groupCycles returns every cycle in the parent/child graph once, each
+// starting and ending with its alphabetically smallest group.
+func (inv *Inventory) groupCycles() [][]string {
+	children := make(map[string][]string)
+	for child, parents := range inv.parentIndex() {
+		for _, p := range parents {
+			if !contains(children[p], child) {
+				children[p] = append(children[p], child)
+			}
+		}
+	}
+	for p := range children {
+		children[p] = sortedCopy(children[p])
+	}
+
+	var cycles [][]string
+	seen := make(map[string]bool)
+	state := make(map[string]int) // 0 new, 1 on stack, 2 done
+	var stack []string
+	var visit func(g string)
+	visit = func(g string) {
+		state[g] = 1
+		stack = append(stack, g)
+		for _, c := range children[g] {
+			switch state[c] {
+			case 0:
+				visit(c)
+			case 1:
+				start := 0
+				for i, s := range stack {
+					if s == c {
+						start = i
+					}
+				}
+				cycle := normaliseCycle(stack[start:])
+				key := strings.Join(cycle, "\x00")
+				if !seen[key] {
+					seen[key] = true
+					cycles = append(cycles, cycle)
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[g] = 2
+	}
+	var names []string
+	for name := range children {
+		names = append(names, name)
+	}
+	for _, name := range sortedCopy(names) {
+		if state[name] == 0 {
+			visit(name)
+		}
+	}
+	return cycles
+}
+
+// normaliseCycle rotates a cycle to start at its smallest member and closes
+// it by repeating that member at the end.
+func normaliseCycle(cycle []string) []string {
+	min := 0
+	for i, g := range cycle {
+		if g < cycle[min] {
+			min = i
+		}
+	}
+	out := append([]string(nil), cycle[min:]...)
+	out = append(out, cycle[:min]...)
+	return append(out, out[0])
+}
+
+func sortedCopy(in []string) []string {
+	out := append([]string(nil), in...)
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,88 @@
+package inventory
+
+import (
+	"strings"
+	"testing"
+)
+
+func findingsByCode(findings []Finding) map[string][]Finding {
+	out := make(map[string][]Finding)
+	for _, f := range findings {
+		out[f.Code] = append(out[f.Code], f)
+	}
+	return out
+}
+
+func TestValidateClean(t *testing.T) {
+	inv := New()
+	inv.AddGroup(&Group{Name: "web"})
+	inv.AddHost(&Host{Name: "web1.example.com", Groups: []string{"web"}, Variables: map[string]any{"ansible_host": "10.0.0.1"}})
+	if f := inv.Validate("ansible_host"); len(f) != 0 {
+		t.Fatalf("expected no findings, got %#v", f)
+	}
+}
+
+func TestValidateFindings(t *testing.T) {
+	inv := New()
+	inv.AddGroup(&Group{Name: "a", Children: []string{"b"}})
+	inv.AddGroup(&Group{Name: "b", Children: []string{"c"}})
+	inv.AddGroup(&Group{Name: "c", Children: []string{"a"}, Parents: []string{"ghost"}})
+	inv.AddGroup(&Group{Name: "web-servers"})
+	inv.AddHost(&Host{Name: "bad host", Groups: []string{"implicit"}, Variables: map[string]any{"ansible_host": "10.0.0.2"}})
+	inv.AddHost(&Host{Name: "noaddr", Groups: []string{"a"}})
+
+	got := findingsByCode(inv.Validate("ansible_host"))
+
+	undeclared := got[CodeUndeclaredGroup]
+	if len(undeclared) != 2 || undeclared[0].Subject != "ghost" || undeclared[1].Subject != "implicit" {
+		t.Fatalf("unexpected undeclared findings: %#v", undeclared)
+	}
+	cycles := got[CodeGroupCycle]
+	if len(cycles) != 1 || cycles[0].Subject != "a -> b -> c -> a" || cycles[0].Severity != SeverityError {
+		t.Fatalf("unexpected cycle findings: %#v", cycles)
+	}
+	if r := got[CodeGroupNameRewritten]; len(r) != 1 || !strings.Contains(r[0].Message, "web_servers") {
+		t.Fatalf("unexpected rewrite findings: %#v", r)
+	}
+	if h := got[CodeInvalidHostName]; len(h) != 1 || h[0].Subject != "bad host" {
+		t.Fatalf("unexpected host name findings: %#v", h)
+	}
+	if m := got[CodeMissingAddress]; len(m) != 1 || m[0].Subject != "noaddr" {
+		t.Fatalf("unexpected address findings: %#v", m)
+	}
+}
+
+func TestValidateAddressTarget(t *testing.T) {
+	inv := New()
+	inv.AddHost(&Host{Name: "web1", Variables: map[string]any{"mgmt_ip": "10.0.0.1"}})
+	inv.AddHost(&Host{Name: "web2", Variables: map[string]any{"ansible_host": "10.0.0.2"}})
+
+	m := findingsByCode(inv.Validate("mgmt_ip"))[CodeMissingAddress]
+	if len(m) != 1 || m[0].Subject != "web2" || !strings.Contains(m[0].Message, "mgmt_ip") {
+		t.Fatalf("unexpected address findings: %#v", m)
+	}
+}
+
+func TestDeclaredSurvivesCopies(t *testing.T) {
+	inv := New()
+	inv.AddHost(&Host{Name: "h1", Groups: []string{"implicit", "web"}})
+	inv.AddGroup(&Group{Name: "web"})
+
+	c := inv.CopyFiltered(nil, nil)
+	if c.Groups["implicit"].Declared || !c.Groups["web"].Declared {
+		t.Fatalf("declared flags not preserved by CopyFiltered")
+	}
+	m := New()
+	if err := m.Merge(inv, LastWins); err != nil {
+		t.Fatalf("merge error: %v", err)
+	}
+	if m.Groups["implicit"].Declared || !m.Groups["web"].Declared {
+		t.Fatalf("declared flags not preserved by Merge")
+	}
+}
+
+func TestSafeGroupName(t *testing.T) {
+	if got := SafeGroupName("eu-west.prod"); got != "eu_west_prod" {
+		t.Fatalf("unexpected safe name %q", got)
+	}
+}
@@ -0,0 +1,136 @@
+package inventory
+
+import "sort"
+
+// HostVars returns the variables Ansible would resolve for the named host:
+// inventory variables first, then the variables of every group the host is a
+// member of (directly or through a child group) ordered by depth and name,
+// and finally the host's own variables. Later sources win. Nil is returned
+// for unknown hosts.
+func (inv *Inventory) HostVars(name string) map[string]any {
+	h, ok := inv.Hosts[name]
+	if !ok {
+		return nil
+	}
+	vars := make(map[string]any)
+	for k, v := range inv.Vars {
+		vars[k] = v
+	}
+	for _, g := range inv.HostGroups(name) {
+		for k, v := range inv.Groups[g].Variables {
+			vars[k] = v
+		}
+	}
+	for k, v := range h.Variables {
+		vars[k] = v
+	}
+	return vars
+}
+
+// HostSensitive reports which of the variables returned by HostVars are
+// sensitive. A variable takes the sensitivity of the level its value comes
+// from, so a host overriding a sensitive group variable with its own value
+// decides on its own. Nil is returned for unknown hosts.
+func (inv *Inventory) HostSensitive(name string) map[string]bool {
+	h, ok := inv.Hosts[name]
+	if !ok {
+		return nil
+	}
+	sensitive := make(map[string]bool)
+	for k := range inv.Vars {
+		sensitive[k] = inv.Sensitive[k]
+	}
+	for _, g := range inv.HostGroups(name) {
+		grp := inv.Groups[g]
+		for k := range grp.Variables {
+			sensitive[k] = grp.Sensitive[k]
+		}
+	}
+	for k := range h.Variables {
+		sensitive[k] = h.Sensitive[k]
+	}
+	return sensitive
+}
+
+// HostGroups returns all groups the named host belongs to, including the
+// ancestors of its direct groups, sorted the way Ansible merges group
+// variables: by depth in the hierarchy, then by name.
+func (inv *Inventory) HostGroups(name string) []string {
+	h, ok := inv.Hosts[name]
+	if !ok {
+		return nil
+	}
+	parents := inv.parentIndex()
+	seen := make(map[string]bool)
+	var walk func(g string)
+	walk = func(g string) {
+		if seen[g] {
+			return
+		}
+		if _, ok := inv.Groups[g]; !ok {
+			return
+		}
+		seen[g] = true
+		for _, p := range parents[g] {
+			walk(p)
+		}
+	}
+	for _, g := range h.Groups {
+		walk(g)
+	}
+
+	depths := make(map[string]int)
+	out := make([]string, 0, len(seen))
+	for g := range seen {
+		groupDepth(g, parents, depths, make(map[string]bool))
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if depths[out[i]] != depths[out[j]] {
+			return depths[out[i]] < depths[out[j]]
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
+
+// parentIndex maps every group to its parents, taking both Group.Parents and
+// the Children lists of other groups into account.
+func (inv *Inventory) parentIndex() map[string][]string {
+	parents := make(map[string][]string, len(inv.Groups))
+	add := func(child, parent string) {
+		if !contains(parents[child], parent) {
+			parents[child] = append(parents[child], parent)
+		}
+	}
+	for name, g := range inv.Groups {
+		for _, p := range g.Parents {
+			add(name, p)
+		}
+		for _, c := range g.Children {
+			add(c, name)
+		}
+	}
+	return parents
+}
+
+// groupDepth returns the length of the longest parent chain above name,
+// memoised in depths. Groups on a cycle stop counting once revisited.
+func groupDepth(name string, parents map[string][]string, depths map[string]int, visiting map[string]bool) int {
+	if d, ok := depths[name]; ok {
+		return d
+	}
+	if visiting[name] {
+		return 0
+	}
+	visiting[name] = true
+	depth := 0
+	for _, p := range parents[name] {
+		if d := groupDepth(p, parents, depths, visiting) + 1; d > depth {
+			depth = d
+		}
+	}
+	delete(visiting, name)
+	depths[name] = depth
+	return depth
+}
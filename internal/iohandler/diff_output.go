@@ -0,0 +1,193 @@
+package iohandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+// OutputDiff writes the changes between two inventories to w as text, as a
+// JSON document or as Markdown suitable for a pull request comment.
+func OutputDiff(w io.Writer, d *inventory.Diff, format string) error {
+	switch format {
+	case "json":
+		return writeJSON(w, d)
+	case "text":
+		_, err := io.WriteString(w, diffText(d))
+		return err
+	case "markdown", "md":
+		_, err := io.WriteString(w, diffMarkdown(d))
+		return err
+	default:
+		return fmt.Errorf("unknown diff format: %s", format)
+	}
+}
+
+func diffText(d *inventory.Diff) string {
+	if d.Empty() {
+		return "no changes\n"
+	}
+	var b strings.Builder
+	list := func(label string, names []string) {
+		if len(names) > 0 {
+			fmt.Fprintf(&b, "%s (%d): %s\n", label, len(names), strings.Join(names, ", "))
+		}
+	}
+	list("hosts added", d.AddedHosts)
+	list("hosts removed", d.RemovedHosts)
+	list("groups added", d.AddedGroups)
+	list("groups removed", d.RemovedGroups)
+
+	for _, g := range d.Groups {
+		fmt.Fprintf(&b, "group %s:\n", g.Name)
+		for _, h := range g.AddedHosts {
+			fmt.Fprintf(&b, "  + host %s\n", h)
+		}
+		for _, h := range g.RemovedHosts {
+			fmt.Fprintf(&b, "  - host %s\n", h)
+		}
+		for _, c := range g.AddedChildren {
+			fmt.Fprintf(&b, "  + child %s\n", c)
+		}
+		for _, c := range g.RemovedChildren {
+			fmt.Fprintf(&b, "  - child %s\n", c)
+		}
+		writeVarChanges(&b, g.Vars)
+	}
+	for _, h := range d.Hosts {
+		fmt.Fprintf(&b, "host %s:\n", h.Name)
+		for _, g := range h.AddedGroups {
+			fmt.Fprintf(&b, "  + group %s\n", g)
+		}
+		for _, g := range h.RemovedGroups {
+			fmt.Fprintf(&b, "  - group %s\n", g)
+		}
+		if h.Enabled != nil {
+			fmt.Fprintf(&b, "  ~ enabled: %t -> %t\n", !*h.Enabled, *h.Enabled)
+		}
+		writeVarChanges(&b, h.Vars)
+	}
+	if len(d.Vars) > 0 {
+		b.WriteString("inventory:\n")
+		writeVarChanges(&b, d.Vars)
+	}
+	return b.String()
+}
+
+func writeVarChanges(b *strings.Builder, changes []inventory.VarChange) {
+	for _, c := range changes {
+		switch c.Kind {
+		case inventory.VarAdded:
+			fmt.Fprintf(b, "  + %s = %s\n", c.Name, diffValue(c.New))
+		case inventory.VarRemoved:
+			fmt.Fprintf(b, "  - %s (was %s)\n", c.Name, diffValue(c.Old))
+		default:
+			fmt.Fprintf(b, "  ~ %s: %s -> %s\n", c.Name, diffValue(c.Old), diffValue(c.New))
+		}
+	}
+}
+
+func diffMarkdown(d *inventory.Diff) string {
+	var b strings.Builder
+	b.WriteString("### Inventory changes\n\n")
+	if d.Empty() {
+		b.WriteString("No inventory changes.\n")
+		return b.String()
+	}
+
+	b.WriteString("| | Added | Removed |\n|---|---|---|\n")
+	fmt.Fprintf(&b, "| Hosts | %d | %d |\n", len(d.AddedHosts), len(d.RemovedHosts))
+	fmt.Fprintf(&b, "| Groups | %d | %d |\n\n", len(d.AddedGroups), len(d.RemovedGroups))
+	list := func(label string, names []string) {
+		if len(names) > 0 {
+			fmt.Fprintf(&b, "**%s:** %s\n\n", label, mdNames(names, ""))
+		}
+	}
+	list("Hosts added", d.AddedHosts)
+	list("Hosts removed", d.RemovedHosts)
+	list("Groups added", d.AddedGroups)
+	list("Groups removed", d.RemovedGroups)
+
+	var membership []string
+	for _, g := range d.Groups {
+		added := joinNonEmpty(mdNames(g.AddedHosts, ""), mdNames(g.AddedChildren, "group "))
+		removed := joinNonEmpty(mdNames(g.RemovedHosts, ""), mdNames(g.RemovedChildren, "group "))
+		if added != "" || removed != "" {
+			membership = append(membership, fmt.Sprintf("| %s | %s | %s |\n", mdCode(g.Name), added, removed))
+		}
+	}
+	if len(membership) > 0 {
+		b.WriteString("#### Group membership\n\n| Group | Added | Removed |\n|---|---|---|\n")
+		b.WriteString(strings.Join(membership, ""))
+		b.WriteString("\n")
+	}
+
+	var vars []string
+	addVars := func(scope string, changes []inventory.VarChange) {
+		for _, c := range changes {
+			vars = append(vars, fmt.Sprintf("| %s | %s | %s | %s |\n", scope, mdCode(c.Name), mdValue(c.Old, c.Kind != inventory.VarAdded), mdValue(c.New, c.Kind != inventory.VarRemoved)))
+		}
+	}
+	for _, g := range d.Groups {
+		addVars("group "+mdCode(g.Name), g.Vars)
+	}
+	for _, h := range d.Hosts {
+		scope := "host " + mdCode(h.Name)
+		if h.Enabled != nil {
+			vars = append(vars, fmt.Sprintf("| %s | *enabled* | `%t` | `%t` |\n", scope, !*h.Enabled, *h.Enabled))
+		}
+		addVars(scope, h.Vars)
+	}
+	addVars("inventory", d.Vars)
+	if len(vars) > 0 {
+		b.WriteString("#### Variables\n\n| Scope | Variable | Old | New |\n|---|---|---|---|\n")
+		b.WriteString(strings.Join(vars, ""))
+	}
+	return b.String()
+}
+
+// diffValue renders a variable value as JSON.
+func diffValue(v any) string {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(buf)
+}
+
+func mdValue(v any, present bool) string {
+	if !present {
+		return ""
+	}
+	return mdCode(diffValue(v))
+}
+
+// mdCode renders s as inline code that is safe inside a table cell.
+func mdCode(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+func mdNames(names []string, prefix string) string {
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		out = append(out, prefix+mdCode(n))
+	}
+	return strings.Join(out, ", ")
+}
+
+func joinNonEmpty(parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,82 @@
+package iohandler
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+func diffOutputFixture() *inventory.Diff {
+	old := inventory.New()
+	old.AddVars(map[string]any{"env": "staging"})
+	old.AddHost(&inventory.Host{Name: "web1", Groups: []string{"prod"}, Variables: map[string]any{"os": "debian"}, Enabled: true})
+	old.AddHost(&inventory.Host{Name: "db1", Enabled: true})
+
+	cur := inventory.New()
+	cur.AddVars(map[string]any{"env": "prod"})
+	cur.AddHost(&inventory.Host{Name: "web1", Variables: map[string]any{"os": "a|b"}, Enabled: true})
+	cur.AddHost(&inventory.Host{Name: "web2", Groups: []string{"prod"}, Enabled: true})
+	cur.AddGroup(&inventory.Group{Name: "prod"})
+	return cur.Compare(old)
+}
+
+func TestOutputDiffText(t *testing.T) {
+	out, err := render(func(w io.Writer) error { return OutputDiff(w, diffOutputFixture(), "text") })
+	want := "hosts added (1): web2\n" +
+		"hosts removed (1): db1\n" +
+		"group prod:\n" +
+		"  + host web2\n" +
+		"  - host web1\n" +
+		"host web1:\n" +
+		"  - group prod\n" +
+		"  ~ os: \"debian\" -> \"a|b\"\n" +
+		"host web2:\n" +
+		"  + group prod\n" +
+		"inventory:\n" +
+		"  ~ env: \"staging\" -> \"prod\"\n"
+	if err != nil || out != want {
+		t.Fatalf("unexpected text output:\n%s\nwant:\n%s", out, want)
+	}
+
+	out, _ = render(func(w io.Writer) error { return OutputDiff(w, &inventory.Diff{}, "text") })
+	if out != "no changes\n" {
+		t.Fatalf("unexpected empty output %q", out)
+	}
+}
+
+func TestOutputDiffMarkdown(t *testing.T) {
+	out, err := render(func(w io.Writer) error { return OutputDiff(w, diffOutputFixture(), "markdown") })
+	want := "### Inventory changes\n\n" +
+		"| | Added | Removed |\n|---|---|---|\n" +
+		"| Hosts | 1 | 1 |\n" +
+		"| Groups | 0 | 0 |\n\n" +
+		"**Hosts added:** `web2`\n\n" +
+		"**Hosts removed:** `db1`\n\n" +
+		"#### Group membership\n\n| Group | Added | Removed |\n|---|---|---|\n" +
+		"| `prod` | `web2` | `web1` |\n\n" +
+		"#### Variables\n\n| Scope | Variable | Old | New |\n|---|---|---|---|\n" +
+		"| host `web1` | `os` | `\"debian\"` | `\"a\\|b\"` |\n" +
+		"| inventory | `env` | `\"staging\"` | `\"prod\"` |\n"
+	if err != nil || out != want {
+		t.Fatalf("unexpected markdown output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestOutputDiffJSON(t *testing.T) {
+	out, err := render(func(w io.Writer) error { return OutputDiff(w, diffOutputFixture(), "json") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d inventory.Diff
+	if err := json.Unmarshal([]byte(out), &d); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(d.AddedHosts) != 1 || len(d.Groups) != 1 || d.Groups[0].Name != "prod" || len(d.Vars) != 1 || d.Vars[0].Kind != "changed" {
+		t.Fatalf("unexpected json output: %s", out)
+	}
+	if err := OutputDiff(io.Discard, &inventory.Diff{}, "bogus"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
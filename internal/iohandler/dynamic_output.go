@@ -0,0 +1,164 @@
+package iohandler
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+// dynamicGroup is a group entry of the Ansible dynamic inventory protocol.
+type dynamicGroup struct {
+	Hosts    []string       `json:"hosts,omitempty"`
+	Vars     map[string]any `json:"vars,omitempty"`
+	Children []string       `json:"children,omitempty"`
+}
+
+// OutputDynamicList writes the inventory to w as the JSON document Ansible
+// expects from a dynamic inventory script called with --list. Host variables
+// are included under _meta.hostvars so Ansible does not call --host per host.
+// The variables, hosts and children of groups named all or ungrouped go into
+// the entries the output synthesizes for them.
+func OutputDynamicList(w io.Writer, inv *inventory.Inventory) error {
+	out := make(map[string]any)
+
+	hostvars := make(map[string]map[string]any, len(inv.Hosts))
+	for _, name := range sortedKeys(inv.Hosts) {
+		hostvars[name] = hostVars(inv.Hosts[name])
+	}
+	out["_meta"] = map[string]any{"hostvars": hostvars}
+
+	all := &dynamicGroup{Vars: implicitVars(inv, "all", inv.Vars), Children: topGroups(inv)}
+	all.Children = append(all.Children, "ungrouped")
+	for _, gname := range sortedKeys(inv.Groups) {
+		if isImplicitGroup(gname) {
+			continue
+		}
+		g := inv.Groups[gname]
+		out[gname] = &dynamicGroup{
+			Hosts:    sortedSlice(g.Hosts),
+			Vars:     g.Variables,
+			Children: sortedSlice(g.Children),
+		}
+	}
+	out["all"] = all
+	out["ungrouped"] = &dynamicGroup{Hosts: ungroupedHosts(inv), Vars: implicitVars(inv, "ungrouped", nil)}
+
+	return writeJSON(w, out)
+}
+
+// isImplicitGroup reports whether Ansible creates the group name itself.
+func isImplicitGroup(name string) bool {
+	return name == "all" || name == "ungrouped"
+}
+
+// implicitVars returns vars together with the variables of the group name,
+// which win like they do in HostVars, or nil when there are none.
+func implicitVars(inv *inventory.Inventory, name string, vars map[string]any) map[string]any {
+	out := make(map[string]any, len(vars))
+	for k, v := range vars {
+		out[k] = v
+	}
+	if g, ok := inv.Groups[name]; ok {
+		for k, v := range g.Variables {
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// topGroups returns the sorted children of all: the groups no other group
+// lists as a child and the children of a group named all.
+func topGroups(inv *inventory.Inventory) []string {
+	isChild := make(map[string]bool)
+	for gname, g := range inv.Groups {
+		if gname == "all" {
+			continue
+		}
+		for _, c := range g.Children {
+			isChild[c] = true
+		}
+	}
+	var top []string
+	for _, gname := range sortedKeys(inv.Groups) {
+		if !isImplicitGroup(gname) && !isChild[gname] {
+			top = append(top, gname)
+		}
+	}
+	return top
+}
+
+// ungroupedHosts returns the sorted hosts that belong to no group other than
+// all and ungrouped.
+func ungroupedHosts(inv *inventory.Inventory) []string {
+	var out []string
+	for _, name := range sortedKeys(inv.Hosts) {
+		grouped := false
+		for _, g := range inv.Hosts[name].Groups {
+			if !isImplicitGroup(g) {
+				grouped = true
+				break
+			}
+		}
+		if !grouped {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// OutputDynamicHost writes the variables of a single host to w as expected
+// from a dynamic inventory script called with --host. Unknown hosts yield an
+// empty object.
+func OutputDynamicHost(w io.Writer, inv *inventory.Inventory, name string) error {
+	vars := map[string]any{}
+	if h, ok := inv.Hosts[name]; ok {
+		vars = hostVars(h)
+	}
+	return writeJSON(w, vars)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// outputAnsibleList writes the inventory exactly like
+// "ansible-inventory --list": group and inventory variables are resolved into
+// _meta.hostvars, groups only carry hosts and children, and groups that end up
+// empty are left out.
+func outputAnsibleList(w io.Writer, inv *inventory.Inventory) error {
+	out := make(map[string]any)
+
+	hostvars := make(map[string]map[string]any)
+	for _, name := range sortedKeys(inv.Hosts) {
+		if vars := inv.HostVars(name); len(vars) > 0 {
+			hostvars[name] = vars
+		}
+	}
+	out["_meta"] = map[string]any{"hostvars": hostvars}
+
+	out["all"] = &dynamicGroup{Children: append([]string{"ungrouped"}, topGroups(inv)...)}
+	for _, gname := range sortedKeys(inv.Groups) {
+		g := inv.Groups[gname]
+		if isImplicitGroup(gname) || len(g.Hosts) == 0 && len(g.Children) == 0 {
+			continue
+		}
+		out[gname] = &dynamicGroup{
+			Hosts:    sortedSlice(g.Hosts),
+			Children: sortedSlice(g.Children),
+		}
+	}
+	if ungrouped := ungroupedHosts(inv); len(ungrouped) > 0 {
+		out["ungrouped"] = &dynamicGroup{Hosts: ungrouped}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,140 @@
+package iohandler
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+func TestOutputDynamicList(t *testing.T) {
+	inv := invFixture()
+	inv.AddHost(&inventory.Host{Name: "lonely"})
+	inv.AddGroup(&inventory.Group{Name: "prod", Children: []string{"web"}})
+	out, err := render(func(w io.Writer) error { return OutputDynamicList(w, inv) })
+	if err != nil {
+		t.Fatalf("list output error: %v", err)
+	}
+	var data map[string]struct {
+		Hosts    []string                  `json:"hosts"`
+		Vars     map[string]any            `json:"vars"`
+		Children []string                  `json:"children"`
+		Hostvars map[string]map[string]any `json:"hostvars"`
+	}
+	if err := json.Unmarshal([]byte(out), &data); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	if data["_meta"].Hostvars["test1"]["ansible_host"] != "192.168.1.10" {
+		t.Fatalf("hostvars missing: %s", out)
+	}
+	if data["all"].Vars["env"] != "test" {
+		t.Fatalf("all vars missing: %s", out)
+	}
+	if len(data["all"].Children) != 2 || data["all"].Children[0] != "prod" || data["all"].Children[1] != "ungrouped" {
+		t.Fatalf("unexpected all children: %v", data["all"].Children)
+	}
+	if len(data["prod"].Children) != 1 || data["prod"].Children[0] != "web" {
+		t.Fatalf("prod children missing: %s", out)
+	}
+	if len(data["web"].Hosts) != 1 || data["web"].Vars["tier"] != "frontend" {
+		t.Fatalf("web group wrong: %s", out)
+	}
+	if len(data["ungrouped"].Hosts) != 1 || data["ungrouped"].Hosts[0] != "lonely" {
+		t.Fatalf("ungrouped hosts wrong: %s", out)
+	}
+}
+
+func TestOutputDynamicListDeclaredAll(t *testing.T) {
+	inv := invFixture()
+	inv.AddGroup(&inventory.Group{Name: "all", Variables: map[string]any{"ntp": "pool", "env": "prod"}, Children: []string{"site"}})
+	inv.AddGroup(&inventory.Group{Name: "site", Children: []string{"web"}})
+	inv.AddHost(&inventory.Host{Name: "bare", Groups: []string{"all"}})
+	out, err := render(func(w io.Writer) error { return OutputDynamicList(w, inv) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]dynamicGroup
+	if err := json.Unmarshal([]byte(out), &data); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	all := data["all"]
+	if all.Vars["ntp"] != "pool" || all.Vars["env"] != "prod" {
+		t.Fatalf("vars of the declared all group dropped: %s", out)
+	}
+	if len(all.Children) != 2 || all.Children[0] != "site" || all.Children[1] != "ungrouped" {
+		t.Fatalf("unexpected all children: %v", all.Children)
+	}
+	if u := data["ungrouped"].Hosts; len(u) != 1 || u[0] != "bare" {
+		t.Fatalf("host only in all should be ungrouped: %v", u)
+	}
+
+	out, err = render(func(w io.Writer) error { return outputAnsibleList(w, inv) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = nil
+	if err := json.Unmarshal([]byte(out), &data); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	if c := data["all"].Children; len(c) != 2 || c[0] != "ungrouped" || c[1] != "site" {
+		t.Fatalf("unexpected all children: %v", c)
+	}
+}
+
+func TestOutputDynamicHost(t *testing.T) {
+	inv := invFixture()
+	out, err := render(func(w io.Writer) error { return OutputDynamicHost(w, inv, "test1") })
+	if err != nil {
+		t.Fatalf("host output error: %v", err)
+	}
+	var vars map[string]any
+	if err := json.Unmarshal([]byte(out), &vars); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if vars["ansible_host"] != "192.168.1.10" || vars["os"] != "linux" {
+		t.Fatalf("unexpected host vars: %v", vars)
+	}
+
+	out, err = render(func(w io.Writer) error { return OutputDynamicHost(w, inv, "missing") })
+	if err != nil || out != "{}\n" {
+		t.Fatalf("expected empty object for unknown host, got %q (%v)", out, err)
+	}
+}
+
+func TestOutputInventoryAnsible(t *testing.T) {
+	inv := invFixture()
+	inv.AddGroup(&inventory.Group{Name: "empty"})
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "ansible") })
+	if err != nil {
+		t.Fatalf("ansible output error: %v", err)
+	}
+	want := `{
+    "_meta": {
+        "hostvars": {
+            "test1": {
+                "ansible_host": "192.168.1.10",
+                "env": "test",
+                "os": "linux",
+                "tier": "frontend"
+            }
+        }
+    },
+    "all": {
+        "children": [
+            "ungrouped",
+            "empty",
+            "web"
+        ]
+    },
+    "web": {
+        "hosts": [
+            "test1"
+        ]
+    }
+}
+`
+	if out != want {
+		t.Fatalf("unexpected ansible output:\n%s", out)
+	}
+}
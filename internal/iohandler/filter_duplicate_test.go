@@ -1,6 +1,7 @@
 package iohandler
 
 import (
+	"io"
 	"strings"
 	"testing"
 
@@ -9,11 +10,12 @@ import (
 
 func TestINIFilterNoDuplicates(t *testing.T) {
 	inv := inventory.New()
-	inv.AddHost(&inventory.Host{Name: "h1", Groups: []string{"web"}, Variables: map[string]string{"ip": "1.2.3.4"}})
+	inv.AddHost(&inventory.Host{Name: "h1", Groups: []string{"web"}, Variables: map[string]any{"ip": "1.2.3.4"}})
 	inv.AddGroup(&inventory.Group{Name: "web", Hosts: []string{"h1"}})
+	inv.MapAddresses(inventory.DefaultAddressMapping())
 
 	inv = inv.CopyFiltered([]string{"h1"}, nil)
-	out, err := captureOutput(func() error { return OutputInventory(inv, "ini") })
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "ini") })
 	if err != nil {
 		t.Fatalf("ini output error: %v", err)
 	}
@@ -0,0 +1,35 @@
+package iohandler
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+// OutputFindings writes validation findings to w as text lines or as a JSON
+// document with a "valid" flag and the list of findings.
+func OutputFindings(w io.Writer, findings []inventory.Finding, valid bool, format string) error {
+	switch format {
+	case "json":
+		if findings == nil {
+			findings = []inventory.Finding{}
+		}
+		return writeJSON(w, struct {
+			Valid    bool                `json:"valid"`
+			Findings []inventory.Finding `json:"findings"`
+		}{valid, findings})
+	case "text":
+		var out string
+		for _, f := range findings {
+			out += fmt.Sprintf("%s %s %s: %s\n", f.Severity, f.Code, f.Subject, f.Message)
+		}
+		if len(findings) == 0 {
+			out = "inventory is valid\n"
+		}
+		_, err := io.WriteString(w, out)
+		return err
+	default:
+		return fmt.Errorf("unknown findings format: %s", format)
+	}
+}
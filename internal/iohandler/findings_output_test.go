@@ -0,0 +1,41 @@
+package iohandler
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+func TestOutputFindings(t *testing.T) {
+	findings := []inventory.Finding{{Severity: inventory.SeverityError, Code: inventory.CodeGroupCycle, Subject: "a -> a", Message: "groups form a cycle: a -> a"}}
+
+	out, err := render(func(w io.Writer) error { return OutputFindings(w, findings, false, "text") })
+	if err != nil || out != "error group-cycle a -> a: groups form a cycle: a -> a\n" {
+		t.Fatalf("unexpected text output %q (%v)", out, err)
+	}
+
+	out, err = render(func(w io.Writer) error { return OutputFindings(w, findings, false, "json") })
+	if err != nil {
+		t.Fatalf("json output error: %v", err)
+	}
+	var doc struct {
+		Valid    bool                `json:"valid"`
+		Findings []inventory.Finding `json:"findings"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if doc.Valid || len(doc.Findings) != 1 || doc.Findings[0].Code != "group-cycle" {
+		t.Fatalf("unexpected json output: %s", out)
+	}
+
+	out, _ = render(func(w io.Writer) error { return OutputFindings(w, nil, true, "json") })
+	if out != "{\n  \"valid\": true,\n  \"findings\": []\n}\n" {
+		t.Fatalf("unexpected empty json output %q", out)
+	}
+	if err := OutputFindings(io.Discard, nil, true, "bogus"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
@@ -0,0 +1,219 @@
+package iohandler
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/vault"
+)
+
+// manifestName is the file in every variables directory that lists the
+// files terraform-ansible-inventory wrote there. Only those files are ever
+// replaced by another layout or removed; Ansible ignores the manifest as no
+// host or group carries its name.
+const manifestName = ".terraform-ansible-inventory"
+
+// WriteHostVars writes the variables of every host into a host_vars layout
+// below dir, see writeVars. enc may be nil. Hosts without variables get no
+// file. The files an earlier run wrote for hosts that are not part of inv
+// are left in place and returned, so the caller can report them.
+func WriteHostVars(dir string, inv *inventory.Inventory, enc *vault.Encrypter) ([]string, error) {
+	vd, err := openVarsDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedKeys(inv.Hosts) {
+		h := inv.Hosts[name]
+		if err := vd.writeVars(name, h.Variables, h.Sensitive, enc); err != nil {
+			return nil, fmt.Errorf("host %s: %w", name, err)
+		}
+	}
+	return vd.close(func(name string) bool { return inv.Hosts[name] != nil })
+}
+
+// varsDir is a host_vars or group_vars directory. It remembers the files
+// listed in the manifest of an earlier run and the files written now.
+type varsDir struct {
+	dir      string
+	previous map[string]bool
+	written  map[string]bool
+}
+
+func openVarsDir(dir string) (*varsDir, error) {
+	vd := &varsDir{dir: dir, previous: make(map[string]bool), written: make(map[string]bool)}
+	f, err := os.Open(filepath.Join(dir, manifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return vd, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			vd.previous[line] = true
+		}
+	}
+	return vd, sc.Err()
+}
+
+// writeVars writes the variables of the host or group name the way Ansible
+// reads host_vars and group_vars: <name>.yml, or, when enc selects some of
+// the variables, <name>/vars.yml with the plain ones and the vault-encrypted
+// file <name>/vault.yml with the selected ones. Nothing is written without
+// variables. Files of the layout not written for name are removed when an
+// earlier run wrote them, so switching layouts leaves no stale plaintext
+// behind; files written by hand are never touched.
+func (vd *varsDir) writeVars(name string, vars map[string]any, sensitive map[string]bool, enc *vault.Encrypter) error {
+	if name == "." || name == ".." || name == manifestName || strings.ContainsAny(name, `/\`) {
+		if len(vars) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%q cannot be used as a file name", name)
+	}
+	file := name + ".yml"
+	layout := []string{name + "/vars.yml", name + "/vault.yml"}
+	if len(vars) == 0 {
+		return vd.remove(append(layout, file)...)
+	}
+	plain := make(map[string]any)
+	secret := make(map[string]any)
+	for k, v := range vars {
+		if enc != nil && enc.Selects(k, sensitive[k]) {
+			secret[k] = v
+		} else {
+			plain[k] = v
+		}
+	}
+	if len(secret) == 0 {
+		if err := vd.remove(layout...); err != nil {
+			return err
+		}
+		return vd.writeYAML(file, plain)
+	}
+
+	if err := vd.remove(file); err != nil {
+		return err
+	}
+	if len(plain) > 0 {
+		if err := vd.writeYAML(layout[0], plain); err != nil {
+			return err
+		}
+	} else if err := vd.remove(layout[0]); err != nil {
+		return err
+	}
+	data, err := encodeYAML(secret)
+	if err != nil {
+		return err
+	}
+	vt, err := vault.Encrypt(data, enc.Password)
+	if err != nil {
+		return err
+	}
+	return vd.write(layout[1], []byte(vt), 0o600)
+}
+
+func (vd *varsDir) writeYAML(rel string, v any) error {
+	data, err := encodeYAML(v)
+	if err != nil {
+		return err
+	}
+	return vd.write(rel, data, 0o644)
+}
+
+// write writes the file rel below the directory and records it for the
+// manifest.
+func (vd *varsDir) write(rel string, data []byte, perm os.FileMode) error {
+	p := filepath.Join(vd.dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p, data, perm); err != nil {
+		return err
+	}
+	vd.written[rel] = true
+	return nil
+}
+
+// remove removes those of the files rel that an earlier run wrote, and the
+// per-name directory holding them once it is empty.
+func (vd *varsDir) remove(rels ...string) error {
+	for _, rel := range rels {
+		if !vd.previous[rel] || vd.written[rel] {
+			continue
+		}
+		p := filepath.Join(vd.dir, filepath.FromSlash(rel))
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if path.Dir(rel) != "." {
+			if entries, err := os.ReadDir(filepath.Dir(p)); err == nil && len(entries) == 0 {
+				if err := os.Remove(filepath.Dir(p)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// close writes the manifest. Files of an earlier run that belong to a host
+// or group keep rejects are neither removed nor forgotten; the ones still
+// present are returned.
+func (vd *varsDir) close(keep func(name string) bool) ([]string, error) {
+	var stale []string
+	for rel := range vd.previous {
+		name := strings.TrimSuffix(strings.SplitN(rel, "/", 2)[0], ".yml")
+		if vd.written[rel] || keep(name) {
+			continue
+		}
+		p := filepath.Join(vd.dir, filepath.FromSlash(rel))
+		if _, err := os.Stat(p); err == nil {
+			vd.written[rel] = true
+			stale = append(stale, p)
+		}
+	}
+	sort.Strings(stale)
+	manifest := filepath.Join(vd.dir, manifestName)
+	if len(vd.written) == 0 {
+		if err := os.Remove(manifest); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return stale, nil
+	}
+	var b strings.Builder
+	b.WriteString("# Files written by terraform-ansible-inventory, which may replace or remove them.\n")
+	for _, rel := range sortedKeys(vd.written) {
+		b.WriteString(rel + "\n")
+	}
+	if err := os.MkdirAll(vd.dir, 0o755); err != nil {
+		return nil, err
+	}
+	return stale, os.WriteFile(manifest, []byte(b.String()), 0o644)
+}
+
+// encodeYAML renders v as a YAML document with two space indentation.
+func encodeYAML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
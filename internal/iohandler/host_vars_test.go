@@ -0,0 +1,94 @@
+package iohandler
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/vault"
+)
+
+func TestWriteHostVars(t *testing.T) {
+	inv := inventory.New()
+	inv.AddHost(&inventory.Host{Name: "web1", Variables: map[string]any{"os": "linux", "db_password": "pw", "root_pw": "hunter2"}, Sensitive: map[string]bool{"root_pw": true}})
+	inv.AddHost(&inventory.Host{Name: "app1", Variables: map[string]any{"os": "debian"}})
+	inv.AddHost(&inventory.Host{Name: "bare"})
+
+	dir := t.TempDir()
+	enc := &vault.Encrypter{Password: []byte("secret"), Sensitive: true, Pattern: regexp.MustCompile(`password`)}
+	if _, err := WriteHostVars(dir, inv, enc); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "web1", "vars.yml"))
+	if err != nil || string(data) != "os: linux\n" {
+		t.Fatalf("unexpected vars.yml %q (%v)", data, err)
+	}
+	path := filepath.Join(dir, "web1", "vault.yml")
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := vault.Decrypt(string(data), []byte("secret"))
+	if err != nil || string(pt) != "db_password: pw\nroot_pw: hunter2\n" {
+		t.Fatalf("unexpected vault.yml content %q (%v)", pt, err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o600 {
+		t.Errorf("vault.yml should not be world readable, mode %v", fi.Mode())
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "app1.yml"))
+	if err != nil || string(data) != "os: debian\n" {
+		t.Fatalf("hosts without secrets should get a single file, got %q (%v)", data, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 { // web1/, app1.yml and the manifest
+		t.Errorf("hosts without variables should get no file: %v", entries)
+	}
+
+	plainDir := t.TempDir()
+	if _, err := WriteHostVars(plainDir, inv, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(plainDir, "web1.yml")); err != nil {
+		t.Errorf("without an encrypter every host should get a single file: %v", err)
+	}
+
+	bad := inventory.New()
+	bad.AddHost(&inventory.Host{Name: "../evil", Variables: map[string]any{"a": 1}})
+	if _, err := WriteHostVars(t.TempDir(), bad, nil); err == nil {
+		t.Fatal("expected error for a host name with a path separator")
+	}
+}
+
+func TestWriteHostVarsSwitchLayout(t *testing.T) {
+	inv := inventory.New()
+	inv.AddHost(&inventory.Host{Name: "db1", Variables: map[string]any{"db_password": "pw", "os": "linux"}})
+	enc := &vault.Encrypter{Password: []byte("secret"), Pattern: regexp.MustCompile(`password`)}
+
+	dir := t.TempDir()
+	if _, err := WriteHostVars(dir, inv, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteHostVars(dir, inv, enc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "db1.yml")); !os.IsNotExist(err) {
+		t.Fatalf("plaintext db1.yml left next to db1/: %v", err)
+	}
+
+	if _, err := WriteHostVars(dir, inv, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "db1")); !os.IsNotExist(err) {
+		t.Fatalf("db1/ left next to db1.yml: %v", err)
+	}
+
+	inv.Hosts["db1"].Variables = nil
+	if _, err := WriteHostVars(dir, inv, nil); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("file of a host without variables left behind: %v", entries)
+	}
+}
@@ -0,0 +1,86 @@
+package iohandler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/vault"
+)
+
+// WriteInventoryDir writes the inventory as a directory Ansible can read
+// with -i dir: a hosts file in INI format holding only group membership and
+// hierarchy, host_vars/<host>.yml, group_vars/<group>.yml and
+// group_vars/all.yml with the inventory variables. Variables selected by
+// enc, which may be nil, go to vault-encrypted files as described for
+// writeVars. Files an earlier run wrote for hosts and groups that are not
+// part of inv are left in place and returned, so the caller can report
+// them; files written by hand are never touched.
+func WriteInventoryDir(dir string, inv *inventory.Inventory, enc *vault.Encrypter) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hosts"), []byte(hostsINI(inv)), 0o644); err != nil {
+		return nil, err
+	}
+	stale, err := WriteHostVars(filepath.Join(dir, "host_vars"), inv, enc)
+	if err != nil {
+		return nil, err
+	}
+	groupVars, err := openVarsDir(filepath.Join(dir, "group_vars"))
+	if err != nil {
+		return nil, err
+	}
+	if err := groupVars.writeVars("all", inv.Vars, inv.Sensitive, enc); err != nil {
+		return nil, fmt.Errorf("inventory variables: %w", err)
+	}
+	for _, name := range sortedKeys(inv.Groups) {
+		g := inv.Groups[name]
+		if err := groupVars.writeVars(name, g.Variables, g.Sensitive, enc); err != nil {
+			return nil, fmt.Errorf("group %s: %w", name, err)
+		}
+	}
+	staleGroups, err := groupVars.close(func(name string) bool { return name == "all" || inv.Groups[name] != nil })
+	if err != nil {
+		return nil, err
+	}
+	return append(stale, staleGroups...), nil
+}
+
+// hostsINI renders group membership and hierarchy without any variables.
+// Hosts outside of every group come first, so Ansible puts them into
+// ungrouped; every group gets a section, even when empty, so its
+// group_vars apply.
+func hostsINI(inv *inventory.Inventory) string {
+	var b strings.Builder
+	ungrouped := false
+	for _, name := range sortedKeys(inv.Hosts) {
+		if len(inv.Hosts[name].Groups) == 0 {
+			b.WriteString(name + "\n")
+			ungrouped = true
+		}
+	}
+	if ungrouped {
+		b.WriteString("\n")
+	}
+	for _, name := range sortedKeys(inv.Groups) {
+		g := inv.Groups[name]
+		if len(g.Hosts) > 0 || len(g.Children) == 0 {
+			fmt.Fprintf(&b, "[%s]\n", name)
+			for _, h := range sortedSlice(g.Hosts) {
+				b.WriteString(h + "\n")
+			}
+			b.WriteString("\n")
+		}
+		if len(g.Children) > 0 {
+			fmt.Fprintf(&b, "[%s:children]\n", name)
+			for _, c := range sortedSlice(g.Children) {
+				b.WriteString(c + "\n")
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,131 @@
+package iohandler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/vault"
+)
+
+func TestWriteInventoryDir(t *testing.T) {
+	inv := hierarchyFixture()
+	inv.AddVars(map[string]any{"env": "prod", "api_token": "t"})
+	inv.AddGroup(&inventory.Group{Name: "web", Variables: map[string]any{"port": 80}})
+	inv.Hosts["deep1"].Variables["rack"] = "r1"
+
+	dir := t.TempDir()
+	enc := &vault.Encrypter{Password: []byte("pw"), Pattern: regexp.MustCompile(`token`)}
+	if _, err := WriteInventoryDir(dir, inv, enc); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(parts ...string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(append([]string{dir}, parts...)...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if got, want := read("hosts"), hostsINI(inv); got != want {
+		t.Fatalf("hosts file %q, want %q", got, want)
+	}
+	if got := read("group_vars", "web.yml"); got != "port: 80\n" {
+		t.Errorf("unexpected group_vars/web.yml %q", got)
+	}
+	if got := read("group_vars", "all", "vars.yml"); got != "env: prod\n" {
+		t.Errorf("unexpected group_vars/all/vars.yml %q", got)
+	}
+	pt, err := vault.Decrypt(read("group_vars", "all", "vault.yml"), []byte("pw"))
+	if err != nil || string(pt) != "api_token: t\n" {
+		t.Errorf("unexpected group_vars/all/vault.yml %q (%v)", pt, err)
+	}
+	if got := read("host_vars", "deep1.yml"); got != "rack: r1\n" {
+		t.Errorf("unexpected host_vars/deep1.yml %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "host_vars", "dia1.yml")); !os.IsNotExist(err) {
+		t.Errorf("hosts without variables should get no host_vars file")
+	}
+}
+
+func TestWriteInventoryDirKeepsForeignFiles(t *testing.T) {
+	inv := inventory.New()
+	inv.AddGroup(&inventory.Group{Name: "web", Variables: map[string]any{"port": 80}})
+	inv.AddHost(&inventory.Host{Name: "web1", Groups: []string{"web"}, Variables: map[string]any{"os": "linux"}})
+	inv.AddHost(&inventory.Host{Name: "old1", Variables: map[string]any{"root_pw": "hunter2"}})
+
+	dir := t.TempDir()
+	hand := map[string]string{
+		"group_vars/web/vars.yml":   "ntp: pool\n",
+		"group_vars/bare_metal.yml": "ipmi: true\n",
+		"host_vars/appliance1.yml":  "serial: 42\n",
+		"host_vars/README.md":       "hand written",
+		"host_vars/web1/vars.yml":   "rack: r1\n",
+	}
+	for rel, content := range hand {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	enc := &vault.Encrypter{Password: []byte("pw"), Pattern: regexp.MustCompile(`_pw$`)}
+	if _, err := WriteInventoryDir(dir, inv, enc); err != nil {
+		t.Fatal(err)
+	}
+	delete(inv.Hosts, "old1")
+	inv.Hosts["web1"].Variables = map[string]any{}
+	stale, err := WriteInventoryDir(dir, inv, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.Join(dir, "host_vars", "old1", "vault.yml")}
+	if !reflect.DeepEqual(stale, want) {
+		t.Errorf("stale files %v, want %v", stale, want)
+	}
+	for rel, content := range hand {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil || string(data) != content {
+			t.Errorf("hand written %s changed: %q (%v)", rel, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "host_vars", "web1.yml")); !os.IsNotExist(err) {
+		t.Errorf("host_vars/web1.yml of a host without variables left behind: %v", err)
+	}
+	if _, err := os.Stat(want[0]); err != nil {
+		t.Errorf("file of a host no longer in the inventory should be kept: %v", err)
+	}
+
+	// Once removed by hand, a stale file is forgotten.
+	if err := os.RemoveAll(filepath.Join(dir, "host_vars", "old1")); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := WriteInventoryDir(dir, inv, enc); err != nil || len(stale) != 0 {
+		t.Errorf("unexpected stale files %v (%v)", stale, err)
+	}
+}
+
+func TestHostsINI(t *testing.T) {
+	inv := inventory.New()
+	inv.AddHost(&inventory.Host{Name: "lonely", Variables: map[string]any{"a": 1}})
+	inv.AddHost(&inventory.Host{Name: "web2", Groups: []string{"web"}})
+	inv.AddHost(&inventory.Host{Name: "web1", Groups: []string{"web"}})
+	inv.AddGroup(&inventory.Group{Name: "prod", Children: []string{"web"}})
+	inv.AddGroup(&inventory.Group{Name: "empty"})
+
+	want := "lonely\n\n" +
+		"[empty]\n\n" +
+		"[prod:children]\nweb\n\n" +
+		"[web]\nweb1\nweb2\n\n"
+	if got := hostsINI(inv); got != want {
+		t.Fatalf("hostsINI = %q, want %q", got, want)
+	}
+}
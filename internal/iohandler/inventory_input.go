@@ -0,0 +1,524 @@
+package iohandler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/vault"
+)
+
+// ReadInventory parses an inventory in one of the formats OutputInventory
+// writes, so that writing and reading it back round-trips: INI values are
+// read as the JSON formatINIValue writes. Numbers are read as float64, the
+// type Terraform values have, and vault-encrypted values as vault.Value.
+// Hosts are enabled unless an INI host line sets ansible_disabled=true.
+func ReadInventory(r io.Reader, format string) (*inventory.Inventory, error) {
+	switch format {
+	case "json":
+		return readJSONInventory(r)
+	case "yaml":
+		return readYAMLInventory(r)
+	case "ini":
+		return readINIInventory(r, parseINIValue, parseINIValue)
+	case "ansible":
+		return readAnsibleList(r)
+	default:
+		return nil, fmt.Errorf("unknown inventory format: %s", format)
+	}
+}
+
+// ReadInventoryFile reads an existing inventory file. Files ending in .yml
+// or .yaml are read as YAML, .json files as the JSON format or, without its
+// Hosts key, as ansible-inventory --list output, and any other file, such as
+// a plain hosts file, as INI. INI values follow Ansible's rules rather than
+// ReadInventory's: inline host variables are Python literals and the values
+// of [group:vars] sections are strings.
+func ReadInventoryFile(path string) (*inventory.Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := "ini"
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		format = "yaml"
+	case ".json":
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid json inventory: %w", err)
+		}
+		format = "ansible"
+		if _, ok := doc["Hosts"]; ok {
+			format = "json"
+		}
+	}
+	if format == "ini" {
+		return readINIInventory(bytes.NewReader(data), parsePythonLiteral, func(s string) any { return s })
+	}
+	return ReadInventory(bytes.NewReader(data), format)
+}
+
+type groupInput struct {
+	Hosts    map[string]yaml.Node   `yaml:"hosts"`
+	Vars     yaml.Node              `yaml:"vars"`
+	Children map[string]*groupInput `yaml:"children"`
+}
+
+func readYAMLInventory(r io.Reader) (*inventory.Inventory, error) {
+	var doc map[string]*groupInput
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid yaml inventory: %w", err)
+	}
+	inv := inventory.New()
+	for _, name := range sortedKeys(doc) {
+		if err := readYAMLGroup(inv, name, "", doc[name]); err != nil {
+			return nil, err
+		}
+	}
+	return inv, nil
+}
+
+// readYAMLGroup adds the group name below parent together with its hosts
+// and children. The all group holds the inventory variables, and its hosts
+// as well as those of ungrouped belong to no group.
+func readYAMLGroup(inv *inventory.Inventory, name, parent string, g *groupInput) error {
+	if g == nil {
+		g = &groupInput{}
+	}
+	vars, err := yamlVars(&g.Vars)
+	if err != nil {
+		return fmt.Errorf("group %s: %w", name, err)
+	}
+	implicit := name == "all" || name == "ungrouped"
+	switch {
+	case name == "all":
+		inv.AddVars(vars)
+	case implicit:
+	default:
+		grp := &inventory.Group{Name: name, Variables: vars}
+		if parent != "" {
+			grp.Parents = []string{parent}
+		}
+		inv.AddGroup(grp)
+	}
+
+	for _, pattern := range sortedKeys(g.Hosts) {
+		node := g.Hosts[pattern]
+		hv, err := yamlVars(&node)
+		if err != nil {
+			return fmt.Errorf("host %s: %w", pattern, err)
+		}
+		var groups []string
+		if !implicit {
+			groups = []string{name}
+		}
+		if err := addHosts(inv, pattern, hv, groups, true); err != nil {
+			return err
+		}
+	}
+
+	if implicit {
+		name = ""
+	}
+	for _, child := range sortedKeys(g.Children) {
+		if err := readYAMLGroup(inv, child, name, g.Children[child]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlVars reads a mapping of variables; an empty or null node has none.
+func yamlVars(n *yaml.Node) (map[string]any, error) {
+	if n.Kind == 0 || n.Tag == "!!null" {
+		return nil, nil
+	}
+	if n.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: variables must be a mapping", n.Line)
+	}
+	v, err := yamlValue(n)
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]any), nil
+}
+
+// yamlValue decodes a node into plain values, reading "!vault" scalars as
+// vault.Value and other local tags such as "!unsafe" as strings.
+func yamlValue(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return yamlValue(n.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			var k string
+			if err := n.Content[i].Decode(&k); err != nil {
+				return nil, err
+			}
+			v, err := yamlValue(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		l := make([]any, 0, len(n.Content))
+		for _, c := range n.Content {
+			v, err := yamlValue(c)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		return l, nil
+	}
+	if n.Tag == "!vault" {
+		return vault.Value{Ciphertext: n.Value}, nil
+	}
+	if strings.HasPrefix(n.Tag, "!") && !strings.HasPrefix(n.Tag, "!!") {
+		return n.Value, nil
+	}
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	switch x := v.(type) {
+	case int:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case uint64:
+		return float64(x), nil
+	}
+	return v, nil
+}
+
+// readINIInventory reads an INI inventory, converting the text of inline
+// host variables with hostValue and of [group:vars] values with groupValue.
+func readINIInventory(r io.Reader, hostValue, groupValue func(string) any) (*inventory.Inventory, error) {
+	inv := inventory.New()
+	group, kind := "ungrouped", "hosts"
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section %q", n, line)
+			}
+			group, kind = line[1:len(line)-1], "hosts"
+			if name, k, ok := strings.Cut(group, ":"); ok {
+				group, kind = name, k
+			}
+			switch kind {
+			case "hosts", "vars", "children":
+			default:
+				return nil, fmt.Errorf("line %d: unknown section type %q", n, kind)
+			}
+			if group != "all" && group != "ungrouped" {
+				inv.AddGroup(&inventory.Group{Name: group})
+			}
+			continue
+		}
+
+		var err error
+		switch kind {
+		case "hosts":
+			err = readINIHost(inv, group, line, hostValue)
+		case "vars":
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				err = fmt.Errorf("expected key=value, got %q", line)
+				break
+			}
+			vars := map[string]any{strings.TrimSpace(k): groupValue(strings.TrimSpace(v))}
+			if group == "all" {
+				inv.AddVars(vars)
+			} else {
+				inv.AddGroup(&inventory.Group{Name: group, Variables: vars})
+			}
+		case "children":
+			if group == "all" {
+				inv.AddGroup(&inventory.Group{Name: line})
+			} else {
+				inv.AddGroup(&inventory.Group{Name: group, Children: []string{line}})
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// readINIHost adds the host of a host line with its inline variables,
+// converted with value, to group.
+func readINIHost(inv *inventory.Inventory, group, line string, value func(string) any) error {
+	fields, err := splitINI(line)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	vars := make(map[string]any)
+	enabled := true
+	for _, f := range fields[1:] {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return fmt.Errorf("host %s: expected key=value, got %q", fields[0], f)
+		}
+		if k == "ansible_disabled" {
+			enabled = v != "true" && v != "True"
+			continue
+		}
+		vars[k] = value(v)
+	}
+	var groups []string
+	if group != "all" && group != "ungrouped" {
+		groups = []string{group}
+	}
+	return addHosts(inv, fields[0], vars, groups, enabled)
+}
+
+// addHosts adds the hosts of an Ansible host entry, which may hold ranges
+// and a ":port" suffix, each with its own copy of vars.
+func addHosts(inv *inventory.Inventory, entry string, vars map[string]any, groups []string, enabled bool) error {
+	pattern, port := splitHostPort(entry)
+	names, err := inventory.ExpandHostRange(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid host pattern %q: %w", pattern, err)
+	}
+	if names == nil {
+		names = []string{pattern}
+	}
+	for _, name := range names {
+		hv := make(map[string]any, len(vars)+1)
+		for k, v := range vars {
+			hv[k] = v
+		}
+		if port != "" {
+			hv["ansible_port"] = parseINIValue(port)
+		}
+		inv.AddHost(&inventory.Host{Name: name, Variables: hv, Groups: groups, Enabled: enabled})
+	}
+	return nil
+}
+
+// splitHostPort splits the port off "host:port". Colons inside ranges and
+// addresses with several colons, such as IPv6 ones, are left alone.
+func splitHostPort(entry string) (host, port string) {
+	i := strings.LastIndexByte(entry, ':')
+	if i < 0 || i < strings.LastIndexByte(entry, ']') {
+		return entry, ""
+	}
+	outside := 0
+	depth := 0
+	for _, r := range entry {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				outside++
+			}
+		}
+	}
+	port = entry[i+1:]
+	if outside != 1 || port == "" || strings.Trim(port, "0123456789") != "" {
+		return entry, ""
+	}
+	return entry[:i], port
+}
+
+// splitINI splits a host line at unquoted whitespace the way quoteINI
+// expects: single quotes are taken literally, double quotes honour
+// backslash escapes. An unquoted "#" starting a field begins a comment.
+func splitINI(line string) ([]string, error) {
+	var fields []string
+	var cur strings.Builder
+	inField := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inField = true
+		case r == '#' && !inField:
+			return fields, nil
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteRune(r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+	return fields, nil
+}
+
+// parseINIValue reverses formatINIValue: JSON numbers, booleans, null,
+// lists, maps and quoted strings are decoded, anything else is a string.
+// Single-quoted strings, common in hand-written inventories, are unquoted.
+func parseINIValue(s string) any {
+	if s == "" {
+		return s
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return s[1 : len(s)-1]
+	}
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	return v
+}
+
+func readJSONInventory(r io.Reader) (*inventory.Inventory, error) {
+	var in inventory.Inventory
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("invalid json inventory: %w", err)
+	}
+	inv := inventory.New()
+	inv.AddVars(jsonVars(in.Vars))
+	inv.MarkSensitive(sortedKeys(in.Sensitive)...)
+	for _, name := range sortedKeys(in.Groups) {
+		g := in.Groups[name]
+		inv.AddGroup(&inventory.Group{
+			Name:      name,
+			Variables: jsonVars(g.Variables),
+			Children:  g.Children,
+			Sensitive: g.Sensitive,
+		})
+		inv.Groups[name].Declared = g.Declared
+	}
+	for _, name := range sortedKeys(in.Hosts) {
+		h := in.Hosts[name]
+		h.Name = name
+		h.Variables = jsonVars(h.Variables)
+		inv.AddHost(h)
+	}
+	inv.Playbooks = in.Playbooks
+	return inv, nil
+}
+
+// ansibleGroup is a group of ansible-inventory --list output; a plain list
+// of host names is accepted as well.
+type ansibleGroup struct {
+	dynamicGroup
+}
+
+func (g *ansibleGroup) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &g.Hosts); err == nil {
+		return nil
+	}
+	return json.Unmarshal(data, &g.dynamicGroup)
+}
+
+func readAnsibleList(r io.Reader) (*inventory.Inventory, error) {
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid ansible inventory: %w", err)
+	}
+	var meta struct {
+		Hostvars map[string]map[string]any `json:"hostvars"`
+	}
+	if raw, ok := doc["_meta"]; ok {
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return nil, fmt.Errorf("invalid ansible inventory: _meta: %w", err)
+		}
+	}
+
+	inv := inventory.New()
+	for _, name := range sortedKeys(doc) {
+		if name == "_meta" {
+			continue
+		}
+		var g ansibleGroup
+		if err := json.Unmarshal(doc[name], &g); err != nil {
+			return nil, fmt.Errorf("invalid ansible inventory: group %s: %w", name, err)
+		}
+		switch name {
+		case "all":
+			inv.AddVars(jsonVars(g.Vars))
+			for _, c := range g.Children {
+				if c != "ungrouped" {
+					inv.AddGroup(&inventory.Group{Name: c})
+				}
+			}
+		case "ungrouped":
+		default:
+			inv.AddGroup(&inventory.Group{
+				Name:      name,
+				Variables: jsonVars(g.Vars),
+				Children:  g.Children,
+			})
+		}
+		for _, h := range g.Hosts {
+			host := &inventory.Host{Name: h, Variables: jsonVars(meta.Hostvars[h]), Enabled: true}
+			if name != "all" && name != "ungrouped" {
+				host.Groups = []string{name}
+			}
+			inv.AddHost(host)
+		}
+	}
+	for _, h := range sortedKeys(meta.Hostvars) {
+		if _, ok := inv.Hosts[h]; !ok {
+			inv.AddHost(&inventory.Host{Name: h, Variables: jsonVars(meta.Hostvars[h]), Enabled: true})
+		}
+	}
+	return inv, nil
+}
+
+// jsonVars replaces {"__ansible_vault": "..."} values with vault.Value.
+func jsonVars(vars map[string]any) map[string]any {
+	for k, v := range vars {
+		if m, ok := v.(map[string]any); ok && len(m) == 1 {
+			if ct, ok := m["__ansible_vault"].(string); ok {
+				vars[k] = vault.Value{Ciphertext: ct}
+			}
+		}
+	}
+	return vars
+}
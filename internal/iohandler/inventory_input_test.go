@@ -0,0 +1,262 @@
+package iohandler
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/vault"
+)
+
+func TestReadInventoryRoundTrip(t *testing.T) {
+	// YAML has no place for the enabled state, so the hosts are enabled
+	inv := invFixture()
+	inv.Hosts["test1"].Enabled = true
+	inv.AddGroup(&inventory.Group{Name: "prod", Children: []string{"web"}, Variables: map[string]any{"replicas": 3.0}})
+	inv.AddHost(&inventory.Host{Name: "lonely", Variables: map[string]any{"ports": []any{80.0, 443.0}}, Enabled: true})
+
+	for _, format := range []string{"yaml", "ini", "json"} {
+		var buf bytes.Buffer
+		if err := OutputInventory(&buf, inv, format); err != nil {
+			t.Fatalf("%s: write: %v", format, err)
+		}
+		got, err := ReadInventory(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: read: %v", format, err)
+		}
+		if d := got.Compare(inv); !d.Empty() {
+			t.Errorf("%s: round trip differs: %s", format, diffText(d))
+		}
+	}
+}
+
+func TestReadAnsibleList(t *testing.T) {
+	var first bytes.Buffer
+	if err := OutputInventory(&first, invFixture(), "ansible"); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := ReadInventory(bytes.NewReader(first.Bytes()), "ansible")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := inv.Hosts["test1"]; h == nil || h.Variables["tier"] != "frontend" || !slices.Contains(h.Groups, "web") {
+		t.Fatalf("unexpected host: %#v", h)
+	}
+	var second bytes.Buffer
+	if err := OutputInventory(&second, inv, "ansible"); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Fatalf("re-rendered output differs:\n%s\n%s", first.String(), second.String())
+	}
+
+	legacy := `{"web": ["web1", "web2"], "_meta": {"hostvars": {"web1": {"port": 80}, "solo": {}}}}`
+	inv, err = ReadInventory(strings.NewReader(legacy), "ansible")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Groups["web"].Hosts) != 2 || inv.Hosts["web1"].Variables["port"] != 80.0 || inv.Hosts["solo"] == nil {
+		t.Fatalf("unexpected inventory: %#v", inv)
+	}
+}
+
+func TestReadYAMLInventory(t *testing.T) {
+	in := `all:
+  vars:
+    env: prod
+  hosts:
+    solo:
+  children:
+    web:
+      vars:
+        port: 8080
+      hosts:
+        web1:
+          secret: !vault |
+            $ANSIBLE_VAULT;1.1;AES256
+            6162
+          path: !unsafe '{{ not templated }}'
+      children:
+        canary:
+          hosts:
+            web1:
+`
+	inv, err := ReadInventory(strings.NewReader(in), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Vars["env"] != "prod" || inv.Groups["web"].Variables["port"] != 8080.0 {
+		t.Fatalf("unexpected variables: %#v %#v", inv.Vars, inv.Groups["web"].Variables)
+	}
+	if len(inv.Hosts["solo"].Groups) != 0 {
+		t.Fatalf("solo should be ungrouped: %v", inv.Hosts["solo"].Groups)
+	}
+	h := inv.Hosts["web1"]
+	if v, ok := h.Variables["secret"].(vault.Value); !ok || !strings.HasPrefix(v.Ciphertext, "$ANSIBLE_VAULT;1.1;AES256\n") {
+		t.Fatalf("expected vault value, got %#v", h.Variables["secret"])
+	}
+	if h.Variables["path"] != "{{ not templated }}" {
+		t.Fatalf("unexpected unsafe value: %#v", h.Variables["path"])
+	}
+	if !slices.Contains(inv.Groups["web"].Children, "canary") || len(h.Groups) != 2 {
+		t.Fatalf("unexpected hierarchy: %#v %v", inv.Groups["web"], h.Groups)
+	}
+
+	if _, err := ReadInventory(strings.NewReader("all:\n  vars: [1]\n"), "yaml"); err == nil {
+		t.Fatalf("expected error for vars that are not a mapping")
+	}
+}
+
+func TestReadINIInventory(t *testing.T) {
+	in := `# comment
+solo ansible_host=10.0.0.9
+[web]
+web1 ansible_host=10.0.0.1 port=8080 motd='hello world' tags='["a","b"]' ansible_disabled=true
+web2 note="say \"hi\""
+
+[web:vars]
+tier = frontend
+
+[prod:children]
+web
+
+[all:vars]
+env=prod
+`
+	inv, err := ReadInventory(strings.NewReader(in), "ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	web1 := inv.Hosts["web1"]
+	if web1.Enabled || web1.Variables["port"] != 8080.0 || web1.Variables["motd"] != "hello world" {
+		t.Fatalf("unexpected web1: %#v", web1)
+	}
+	if tags, ok := web1.Variables["tags"].([]any); !ok || len(tags) != 2 {
+		t.Fatalf("unexpected tags: %#v", web1.Variables["tags"])
+	}
+	if inv.Hosts["web2"].Variables["note"] != `say "hi"` || !inv.Hosts["web2"].Enabled {
+		t.Fatalf("unexpected web2: %#v", inv.Hosts["web2"])
+	}
+	if len(inv.Hosts["solo"].Groups) != 0 || inv.Vars["env"] != "prod" || inv.Groups["web"].Variables["tier"] != "frontend" {
+		t.Fatalf("unexpected inventory: %#v", inv)
+	}
+	if !slices.Contains(inv.Groups["prod"].Children, "web") {
+		t.Fatalf("prod should contain web: %#v", inv.Groups["prod"])
+	}
+
+	for _, bad := range []string{"[web\nweb1\n", "[web:meta]\n", "web1 port\n", "web1 motd='open\n"} {
+		if _, err := ReadInventory(strings.NewReader(bad), "ini"); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestReadHandWrittenINI(t *testing.T) {
+	in := `[metal]
+rack[1:2]-node[a:b]:2222 datacenter=fra1  # racked in 2019
+switch01 ansible_network_os='ios'
+
+[metal:vars]
+ansible_user='admin'
+`
+	inv, err := ReadInventory(strings.NewReader(in), "ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Groups["metal"].Hosts) != 5 {
+		t.Fatalf("unexpected hosts: %v", inv.Groups["metal"].Hosts)
+	}
+	node := inv.Hosts["rack2-nodeb"]
+	if node == nil || node.Variables["ansible_port"] != 2222.0 || node.Variables["datacenter"] != "fra1" || len(node.Variables) != 2 {
+		t.Fatalf("unexpected host: %#v", node)
+	}
+	if inv.Hosts["switch01"].Variables["ansible_network_os"] != "ios" || inv.Groups["metal"].Variables["ansible_user"] != "admin" {
+		t.Fatalf("single-quoted values should be unquoted: %#v", inv)
+	}
+}
+
+func TestReadInventoryFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"hosts":       "[web]\nweb1\n",
+		"hosts.yml":   "web:\n  hosts:\n    web1:\n",
+		"list.json":   `{"web": {"hosts": ["web1"]}}`,
+		"dump.json":   `{"Hosts": {"web1": {"Groups": ["web"], "Enabled": true}}, "Groups": {"web": {"Hosts": ["web1"]}}}`,
+		"broken.json": `{`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"hosts", "hosts.yml", "list.json", "dump.json"} {
+		inv, err := ReadInventoryFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if h := inv.Hosts["web1"]; h == nil || !slices.Contains(h.Groups, "web") {
+			t.Errorf("%s: web1 should be in web: %#v", name, inv.Hosts)
+		}
+	}
+	if _, err := ReadInventoryFile(filepath.Join(dir, "broken.json")); err == nil {
+		t.Fatal("expected error for invalid json")
+	}
+}
+
+func TestReadInventoryFileINIValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	in := `[db]
+db1:2222 ansible_become=True retries=3 ratio=0.5 motd="hello world" tags="['a', 'b']" ip=10.0.0.1 flag=true
+
+[db:vars]
+ansible_become=True
+port=5432
+greeting='hi'
+`
+	if err := os.WriteFile(path, []byte(in), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := ReadInventoryFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := inv.Hosts["db1"].Variables
+	want := map[string]any{
+		"ansible_port":   2222.0,
+		"ansible_become": true,
+		"retries":        3.0,
+		"ratio":          0.5,
+		"motd":           "hello world",
+		"tags":           []any{"a", "b"},
+		"ip":             "10.0.0.1",
+		"flag":           "true",
+	}
+	if !reflect.DeepEqual(host, want) {
+		t.Fatalf("host values should be python literals:\n got %#v\nwant %#v", host, want)
+	}
+	group := inv.Groups["db"].Variables
+	if group["ansible_become"] != "True" || group["port"] != "5432" || group["greeting"] != "'hi'" {
+		t.Fatalf("group vars should be strings: %#v", group)
+	}
+}
+
+func TestSplitHostPort(t *testing.T) {
+	tests := []struct{ entry, host, port string }{
+		{"web1:2222", "web1", "2222"},
+		{"web[01:03]:22", "web[01:03]", "22"},
+		{"web[01:03]", "web[01:03]", ""},
+		{"fe80::1", "fe80::1", ""},
+		{"web1:ssh", "web1:ssh", ""},
+	}
+	for _, tt := range tests {
+		host, port := splitHostPort(tt.entry)
+		if host != tt.host || port != tt.port {
+			t.Errorf("%s: got %q %q, want %q %q", tt.entry, host, port, tt.host, tt.port)
+		}
+	}
+}
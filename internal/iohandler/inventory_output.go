@@ -3,7 +3,9 @@ package iohandler
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 
@@ -12,32 +14,35 @@ import (
 
 type groupYAML struct {
 	Hosts    map[string]any        `yaml:"hosts,omitempty"`
-	Vars     map[string]string     `yaml:"vars,omitempty"`
+	Vars     map[string]any        `yaml:"vars,omitempty"`
 	Children map[string]*groupYAML `yaml:"children,omitempty"`
 }
 
-// OutputInventory dispatches YAML or INI inventory output.
-func OutputInventory(inv *inventory.Inventory, format string) error {
+// OutputInventory writes the inventory to w as YAML, INI, JSON or
+// ansible-inventory style output.
+func OutputInventory(w io.Writer, inv *inventory.Inventory, format string) error {
 	switch format {
 	case "json":
-		return outputJSONInventory(inv)
+		return outputJSONInventory(w, inv)
 	case "yaml":
-		return outputYAML(inv)
+		return outputYAML(w, inv)
 	case "ini":
-		return outputINIInventory(inv)
+		return outputINIInventory(w, inv)
+	case "ansible":
+		return outputAnsibleList(w, inv)
 	default:
 		return fmt.Errorf("unknown inventory format: %s", format)
 	}
 }
 
-func outputYAML(inv *inventory.Inventory) error {
+func outputYAML(w io.Writer, inv *inventory.Inventory) error {
 	root := &groupYAML{
 		Hosts:    make(map[string]any),
 		Children: make(map[string]*groupYAML),
 	}
 
 	if len(inv.Vars) > 0 {
-		root.Vars = make(map[string]string)
+		root.Vars = make(map[string]any)
 		for k, v := range inv.Vars {
 			root.Vars[k] = v
 		}
@@ -53,36 +58,22 @@ func outputYAML(inv *inventory.Inventory) error {
 		root.Hosts[h.Name] = hostToYAML(h)
 	}
 
-	// prepare groups recursively
-	groupNames := sortedKeys(inv.Groups)
-	for _, gname := range groupNames {
-		g := inv.Groups[gname]
-		gy := ensureGroupYAML(root, g.Name)
-		if len(g.Variables) > 0 {
-			if gy.Vars == nil {
-				gy.Vars = make(map[string]string)
-			}
-			for k, v := range g.Variables {
-				gy.Vars[k] = v
-			}
-		}
-		for _, child := range g.Children {
-			ensureGroupYAML(root, g.Name).Children[child] = ensureGroupYAML(root, child)
+	// nest groups below their parents, starting from the groups without
+	// parents; groups only reachable through a cycle are placed under all
+	visited := make(map[string]bool)
+	for _, gname := range sortedKeys(inv.Groups) {
+		if len(inv.Groups[gname].Parents) == 0 {
+			root.Children[gname] = groupToYAML(inv, gname, visited, map[string]bool{})
 		}
-		for _, host := range g.Hosts {
-			if gy.Hosts == nil {
-				gy.Hosts = make(map[string]any)
-			}
-			if h, ok := inv.Hosts[host]; ok {
-				gy.Hosts[host] = hostToYAML(h)
-			} else {
-				gy.Hosts[host] = struct{}{}
-			}
+	}
+	for _, gname := range sortedKeys(inv.Groups) {
+		if !visited[gname] {
+			root.Children[gname] = groupToYAML(inv, gname, visited, map[string]bool{})
 		}
 	}
 
 	out := map[string]*groupYAML{"all": root}
-	enc := yaml.NewEncoder(stdoutWrapper{})
+	enc := yaml.NewEncoder(w)
 	enc.SetIndent(2)
 	if err := enc.Encode(out); err != nil {
 		return err
@@ -90,59 +81,67 @@ func outputYAML(inv *inventory.Inventory) error {
 	return enc.Close()
 }
 
-type stdoutWrapper struct{}
-
-func (stdoutWrapper) Write(p []byte) (int, error) { return fmt.Print(string(p)) }
-
-// hostToYAML returns either a map of variables including ansible_host or
-// an empty struct if no variables are present.
+// hostToYAML returns either a map of the host's variables or an empty
+// struct if no variables are present.
 func hostToYAML(h *inventory.Host) any {
-	vars := make(map[string]string)
-	for k, v := range h.Variables {
-		vars[k] = v
-	}
-	if ip, ok := vars["ip"]; ok {
-		vars["ansible_host"] = stripCIDR(ip)
-		delete(vars, "ip")
-	}
+	vars := hostVars(h)
 	if len(vars) == 0 {
 		return struct{}{}
 	}
 	return vars
 }
 
-func ensureGroupYAML(root *groupYAML, name string) *groupYAML {
-	parts := []string{name}
-	gy := root
-	for _, p := range parts {
-		if gy.Children == nil {
-			gy.Children = make(map[string]*groupYAML)
-		}
-		if _, ok := gy.Children[p]; !ok {
-			gy.Children[p] = &groupYAML{Children: make(map[string]*groupYAML)}
-		}
-		gy = gy.Children[p]
+// hostVars returns a copy of the host variables.
+func hostVars(h *inventory.Host) map[string]any {
+	vars := make(map[string]any, len(h.Variables))
+	for k, v := range h.Variables {
+		vars[k] = v
 	}
-	return gy
+	return vars
 }
 
-func stripCIDR(ip string) string {
-	if pos := index(ip, '/'); pos >= 0 {
-		return ip[:pos]
-	}
-	return ip
-}
+// groupToYAML renders the group name together with all of its descendants.
+// A group reachable through several parents is rendered below each of them;
+// a child already on the current path closes a cycle and is left out.
+func groupToYAML(inv *inventory.Inventory, name string, visited, path map[string]bool) *groupYAML {
+	visited[name] = true
+	path[name] = true
+	defer delete(path, name)
 
-func index(s string, c byte) int {
-	for i := 0; i < len(s); i++ {
-		if s[i] == c {
-			return i
+	gy := &groupYAML{}
+	g, ok := inv.Groups[name]
+	if !ok {
+		return gy
+	}
+	if len(g.Variables) > 0 {
+		gy.Vars = make(map[string]any, len(g.Variables))
+		for k, v := range g.Variables {
+			gy.Vars[k] = v
+		}
+	}
+	for _, host := range g.Hosts {
+		if gy.Hosts == nil {
+			gy.Hosts = make(map[string]any)
+		}
+		if h, ok := inv.Hosts[host]; ok {
+			gy.Hosts[host] = hostToYAML(h)
+		} else {
+			gy.Hosts[host] = struct{}{}
+		}
+	}
+	for _, child := range sortedSlice(g.Children) {
+		if path[child] {
+			continue
+		}
+		if gy.Children == nil {
+			gy.Children = make(map[string]*groupYAML)
 		}
+		gy.Children[child] = groupToYAML(inv, child, visited, path)
 	}
-	return -1
+	return gy
 }
 
-func outputINIInventory(inv *inventory.Inventory) error {
+func outputINIInventory(w io.Writer, inv *inventory.Inventory) error {
 	var out string
 
 	groups := sortedKeys(inv.Groups)
@@ -161,7 +160,7 @@ func outputINIInventory(inv *inventory.Inventory) error {
 	if len(inv.Vars) > 0 {
 		out += "[all:vars]\n"
 		for _, k := range sortedMapKeys(inv.Vars) {
-			out += fmt.Sprintf("%s=%s\n", k, inv.Vars[k])
+			out += fmt.Sprintf("%s=%s\n", k, formatINIValue(inv.Vars[k]))
 		}
 		out += "\n"
 	}
@@ -178,7 +177,7 @@ func outputINIInventory(inv *inventory.Inventory) error {
 		if len(g.Variables) > 0 {
 			out += fmt.Sprintf("[%s:vars]\n", gname)
 			for _, k := range sortedMapKeys(g.Variables) {
-				out += fmt.Sprintf("%s=%s\n", k, g.Variables[k])
+				out += fmt.Sprintf("%s=%s\n", k, formatINIValue(g.Variables[k]))
 			}
 			out += "\n"
 		}
@@ -191,34 +190,57 @@ func outputINIInventory(inv *inventory.Inventory) error {
 		}
 	}
 
-	_, err := fmt.Print(out)
+	_, err := io.WriteString(w, out)
 	return err
 }
 
 func formatHostINI(h *inventory.Host) string {
 	line := h.Name
-	if ip, ok := h.Variables["ip"]; ok {
-		line += fmt.Sprintf(" ansible_host=%s", stripCIDR(ip))
+	vars := hostVars(h)
+	if addr, ok := vars["ansible_host"]; ok {
+		line += fmt.Sprintf(" ansible_host=%s", quoteINI(formatINIValue(addr)))
 	}
-	for k, v := range h.Variables {
-		if k == "ip" {
+	for _, k := range sortedMapKeys(vars) {
+		if k == "ansible_host" {
 			continue
 		}
-		line += fmt.Sprintf(" %s=%s", k, v)
+		line += fmt.Sprintf(" %s=%s", k, quoteINI(formatINIValue(vars[k])))
 	}
 	if !h.Enabled {
 		line += " ansible_disabled=true"
 	}
-	for k, v := range h.Metadata {
-		line += fmt.Sprintf(" %s=%s", k, v)
-	}
 	return line
 }
 
-func outputJSONInventory(inv *inventory.Inventory) error {
-	enc := json.NewEncoder(stdoutWrapper{})
-	enc.SetIndent("", "  ")
-	return enc.Encode(inv)
+// formatINIValue renders a variable for INI output. Strings are written as
+// they are, every other value is JSON encoded so Ansible can evaluate it back
+// into the original type.
+func formatINIValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(buf)
+}
+
+// quoteINI quotes a host line value so Ansible's shell-style splitting keeps
+// it in one piece and leaves embedded JSON quotes intact.
+func quoteINI(s string) string {
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func outputJSONInventory(w io.Writer, inv *inventory.Inventory) error {
+	return writeJSON(w, inv)
 }
 
 func sortedKeys[T any](m map[string]T) []string {
@@ -236,7 +258,7 @@ func sortedSlice(in []string) []string {
 	return out
 }
 
-func sortedMapKeys(m map[string]string) []string {
+func sortedMapKeys[T any](m map[string]T) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
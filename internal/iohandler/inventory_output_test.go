@@ -2,6 +2,7 @@ package iohandler
 
 import (
 	"encoding/json"
+	"io"
 	"strings"
 	"testing"
 
@@ -11,19 +12,20 @@ import (
 
 func invFixture() *inventory.Inventory {
 	inv := inventory.New()
-	inv.AddVars(map[string]string{"env": "test"})
-	inv.AddGroup(&inventory.Group{Name: "web", Variables: map[string]string{"tier": "frontend"}})
+	inv.AddVars(map[string]any{"env": "test"})
+	inv.AddGroup(&inventory.Group{Name: "web", Variables: map[string]any{"tier": "frontend"}})
 	inv.AddHost(&inventory.Host{
 		Name:      "test1",
 		Groups:    []string{"web"},
-		Variables: map[string]string{"ip": "192.168.1.10/24", "os": "linux"},
+		Variables: map[string]any{"ip": "192.168.1.10/24", "os": "linux"},
 	})
+	inv.MapAddresses(inventory.DefaultAddressMapping())
 	return inv
 }
 
 func TestOutputInventoryJSON(t *testing.T) {
 	inv := invFixture()
-	out, err := captureOutput(func() error { return OutputInventory(inv, "json") })
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "json") })
 	if err != nil {
 		t.Fatalf("json output error: %v", err)
 	}
@@ -38,7 +40,7 @@ func TestOutputInventoryJSON(t *testing.T) {
 
 func TestOutputInventoryYAML(t *testing.T) {
 	inv := invFixture()
-	out, err := captureOutput(func() error { return OutputInventory(inv, "yaml") })
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "yaml") })
 	if err != nil {
 		t.Fatalf("yaml output error: %v", err)
 	}
@@ -79,7 +81,7 @@ func TestOutputInventoryYAML(t *testing.T) {
 
 func TestOutputInventoryINI(t *testing.T) {
 	inv := invFixture()
-	out, err := captureOutput(func() error { return OutputInventory(inv, "ini") })
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "ini") })
 	if err != nil {
 		t.Fatalf("ini output error: %v", err)
 	}
@@ -95,11 +97,11 @@ func TestOutputInventoryINI(t *testing.T) {
 
 func TestYAMLvsINIParity(t *testing.T) {
 	inv := invFixture()
-	yamlOut, err := captureOutput(func() error { return OutputInventory(inv, "yaml") })
+	yamlOut, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "yaml") })
 	if err != nil {
 		t.Fatalf("yaml output error: %v", err)
 	}
-	iniOut, err := captureOutput(func() error { return OutputInventory(inv, "ini") })
+	iniOut, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "ini") })
 	if err != nil {
 		t.Fatalf("ini output error: %v", err)
 	}
@@ -118,15 +120,15 @@ func TestYAMLvsINIParity(t *testing.T) {
 }
 func TestINIYAMLJSONParity(t *testing.T) {
 	inv := invFixture()
-	yamlOut, err := captureOutput(func() error { return OutputInventory(inv, "yaml") })
+	yamlOut, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "yaml") })
 	if err != nil {
 		t.Fatalf("yaml output error: %v", err)
 	}
-	iniOut, err := captureOutput(func() error { return OutputInventory(inv, "ini") })
+	iniOut, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "ini") })
 	if err != nil {
 		t.Fatalf("ini output error: %v", err)
 	}
-	jsonOut, err := captureOutput(func() error { return OutputInventory(inv, "json") })
+	jsonOut, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "json") })
 	if err != nil {
 		t.Fatalf("json output error: %v", err)
 	}
@@ -149,10 +151,10 @@ func TestINIYAMLJSONParity(t *testing.T) {
 		t.Fatalf("unmarshal json: %v", err)
 	}
 	jh := jinv.Hosts["test1"]
-	jIP := strings.SplitN(jh.Variables["ip"], "/", 2)[0]
-	jOS := jh.Variables["os"]
-	jInvVar := jinv.Vars["env"]
-	jGrpVar := jinv.Groups["web"].Variables["tier"]
+	jIP := jh.Variables["ansible_host"].(string)
+	jOS := jh.Variables["os"].(string)
+	jInvVar := jinv.Vars["env"].(string)
+	jGrpVar := jinv.Groups["web"].Variables["tier"].(string)
 
 	if yIP != jIP || yOS != jOS {
 		t.Fatalf("host variables mismatch yaml vs json")
@@ -178,17 +180,219 @@ func TestINIYAMLJSONParity(t *testing.T) {
 
 func TestOutputInventoryUnknownFormat(t *testing.T) {
 	inv := inventory.New()
-	err := OutputInventory(inv, "bogus")
+	err := OutputInventory(io.Discard, inv, "bogus")
 	if err == nil {
 		t.Fatal("expected error for unknown inventory format")
 	}
 }
 
-func TestStripCIDRHelper(t *testing.T) {
-	if stripCIDR("1.2.3.4/32") != "1.2.3.4" {
-		t.Fatalf("stripCIDR failed")
+func typedFixture() *inventory.Inventory {
+	inv := inventory.New()
+	inv.AddVars(map[string]any{"retries": float64(3)})
+	inv.AddGroup(&inventory.Group{Name: "web", Variables: map[string]any{"ports": []any{float64(80), float64(443)}}})
+	inv.AddHost(&inventory.Host{
+		Name:      "test1",
+		Groups:    []string{"web"},
+		Enabled:   true,
+		Variables: map[string]any{"primary": true, "labels": map[string]any{"team": "ops"}, "motd": "hello world"},
+	})
+	return inv
+}
+
+func TestOutputTypedVariablesYAML(t *testing.T) {
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, typedFixture(), "yaml") })
+	if err != nil {
+		t.Fatalf("yaml output error: %v", err)
+	}
+	var data map[string]any
+	if err := yaml.Unmarshal([]byte(out), &data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	all := data["all"].(map[string]any)
+	if all["vars"].(map[string]any)["retries"] != 3 {
+		t.Fatalf("numeric inventory var not preserved:\n%s", out)
+	}
+	web := all["children"].(map[string]any)["web"].(map[string]any)
+	if ports, ok := web["vars"].(map[string]any)["ports"].([]any); !ok || len(ports) != 2 {
+		t.Fatalf("list group var not preserved:\n%s", out)
+	}
+	host := web["hosts"].(map[string]any)["test1"].(map[string]any)
+	if host["primary"] != true || host["labels"].(map[string]any)["team"] != "ops" {
+		t.Fatalf("typed host vars not preserved:\n%s", out)
+	}
+}
+
+func TestOutputTypedVariablesINI(t *testing.T) {
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, typedFixture(), "ini") })
+	if err != nil {
+		t.Fatalf("ini output error: %v", err)
+	}
+	for _, want := range []string{
+		`test1 labels='{"team":"ops"}' motd='hello world' primary=true`,
+		"retries=3\n",
+		"ports=[80,443]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("ini output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestOutputTypedVariablesJSON(t *testing.T) {
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, typedFixture(), "json") })
+	if err != nil {
+		t.Fatalf("json output error: %v", err)
+	}
+	var chk inventory.Inventory
+	if err := json.Unmarshal([]byte(out), &chk); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if chk.Hosts["test1"].Variables["primary"] != true || chk.Vars["retries"] != float64(3) {
+		t.Fatalf("typed values lost in json output:\n%s", out)
+	}
+}
+
+func TestQuoteINI(t *testing.T) {
+	cases := map[string]string{
+		"plain":     "plain",
+		"two words": "'two words'",
+		`{"a":1}`:   `'{"a":1}'`,
+		`it's "x"`:  `"it's \"x\""`,
+	}
+	for in, want := range cases {
+		if got := quoteINI(in); got != want {
+			t.Errorf("quoteINI(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func hierarchyFixture() *inventory.Inventory {
+	inv := inventory.New()
+	// deep: a -> b -> c -> d, with c only naming its parent
+	inv.AddGroup(&inventory.Group{Name: "a", Children: []string{"b"}})
+	inv.AddGroup(&inventory.Group{Name: "c", Parents: []string{"b"}, Children: []string{"d"}})
+	inv.AddHost(&inventory.Host{Name: "deep1", Groups: []string{"d"}, Enabled: true})
+	// diamond: top -> left, right -> bottom
+	inv.AddGroup(&inventory.Group{Name: "top", Children: []string{"left", "right"}})
+	inv.AddGroup(&inventory.Group{Name: "bottom", Parents: []string{"left", "right"}})
+	inv.AddHost(&inventory.Host{Name: "dia1", Groups: []string{"bottom"}, Enabled: true})
+	return inv
+}
+
+func yamlPath(t *testing.T, node any, path ...string) map[string]any {
+	t.Helper()
+	for _, p := range path {
+		m, ok := node.(map[string]any)
+		if !ok {
+			t.Fatalf("no mapping at %q in path %v", p, path)
+		}
+		if node, ok = m[p]; !ok {
+			t.Fatalf("missing %q in path %v", p, path)
+		}
+	}
+	m, _ := node.(map[string]any)
+	return m
+}
+
+func TestOutputYAMLHierarchy(t *testing.T) {
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, hierarchyFixture(), "yaml") })
+	if err != nil {
+		t.Fatalf("yaml output error: %v", err)
+	}
+	var data map[string]any
+	if err := yaml.Unmarshal([]byte(out), &data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	top := yamlPath(t, data, "all", "children")
+	if len(top) != 2 || top["a"] == nil || top["top"] == nil {
+		t.Fatalf("only root groups belong under all: %s", out)
+	}
+	yamlPath(t, data, "all", "children", "a", "children", "b", "children", "c", "children", "d", "hosts", "deep1")
+	yamlPath(t, data, "all", "children", "top", "children", "left", "children", "bottom", "hosts", "dia1")
+	yamlPath(t, data, "all", "children", "top", "children", "right", "children", "bottom", "hosts", "dia1")
+}
+
+func TestOutputYAMLCycle(t *testing.T) {
+	inv := inventory.New()
+	inv.AddGroup(&inventory.Group{Name: "x", Children: []string{"y"}})
+	inv.AddGroup(&inventory.Group{Name: "y", Children: []string{"x"}})
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "yaml") })
+	if err != nil {
+		t.Fatalf("yaml output error: %v", err)
+	}
+	var data map[string]any
+	if err := yaml.Unmarshal([]byte(out), &data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	x := yamlPath(t, data, "all", "children", "x", "children", "y")
+	if _, ok := x["children"]; ok {
+		t.Fatalf("cycle must be cut: %s", out)
+	}
+}
+
+func TestOutputINIHierarchy(t *testing.T) {
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, hierarchyFixture(), "ini") })
+	if err != nil {
+		t.Fatalf("ini output error: %v", err)
+	}
+	for _, want := range []string{
+		"[a:children]\nb\n",
+		"[b:children]\nc\n",
+		"[c:children]\nd\n",
+		"[top:children]\nleft\nright\n",
+		"[left:children]\nbottom\n",
+		"[right:children]\nbottom\n",
+		"[bottom]\ndia1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("ini output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestOutputAnsibleHierarchy(t *testing.T) {
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, hierarchyFixture(), "ansible") })
+	if err != nil {
+		t.Fatalf("ansible output error: %v", err)
+	}
+	var data map[string]struct {
+		Hosts    []string `json:"hosts"`
+		Children []string `json:"children"`
+	}
+	if err := json.Unmarshal([]byte(out), &data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got := data["all"].Children; len(got) != 3 || got[0] != "ungrouped" || got[1] != "a" || got[2] != "top" {
+		t.Fatalf("unexpected all children: %v", got)
+	}
+	if got := data["c"].Children; len(got) != 1 || got[0] != "d" {
+		t.Fatalf("unexpected c children: %v", got)
+	}
+	if len(data["left"].Children) != 1 || len(data["right"].Children) != 1 {
+		t.Fatalf("bottom must be a child of left and right: %s", out)
+	}
+}
+
+func TestOutputKeepsExplicitAnsibleHost(t *testing.T) {
+	inv := inventory.New()
+	inv.AddHost(&inventory.Host{
+		Name:      "h1",
+		Enabled:   true,
+		Variables: map[string]any{"ip": "10.0.0.1/24", "ansible_host": "h1.example.com"},
+	})
+	inv.MapAddresses(inventory.DefaultAddressMapping())
+	out, err := render(func(w io.Writer) error { return OutputInventory(w, inv, "ini") })
+	if err != nil {
+		t.Fatalf("ini output error: %v", err)
+	}
+	if !strings.Contains(out, "h1 ansible_host=h1.example.com\n") {
+		t.Fatalf("unexpected ini output: %s", out)
+	}
+	out, err = render(func(w io.Writer) error { return OutputInventory(w, inv, "yaml") })
+	if err != nil {
+		t.Fatalf("yaml output error: %v", err)
 	}
-	if stripCIDR("1.2.3.4") != "1.2.3.4" {
-		t.Fatalf("stripCIDR modified plain ip")
+	if !strings.Contains(out, "ansible_host: h1.example.com") || strings.Contains(out, "10.0.0.1") {
+		t.Fatalf("unexpected yaml output: %s", out)
 	}
 }
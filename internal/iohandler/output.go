@@ -3,53 +3,57 @@ package iohandler
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"io"
 	"strings"
 )
 
-// Output dispatches to JSON, INI, TXT, or (added) Ansible-inventory formats.
-func Output(data []map[string]interface{}, format string) error {
+// Output writes to w in JSON, INI, TXT, or (added) Ansible-inventory formats.
+func Output(w io.Writer, data []map[string]interface{}, format string) error {
 	switch strings.ToLower(format) {
 	case "json":
-		return outputJSON(data)
+		return outputJSON(w, data)
 	case "ini":
-		return outputINI(data)
+		return outputINI(w, data)
 	case "txt":
-		return outputTXT(data)
+		return outputTXT(w, data)
 	case "ansible":
 		// Not part of the original Output; route through the new helper:
-		return OutputAnsibleInventory(data, "values.name", "values.variables.ip")
+		return OutputAnsibleInventory(w, data, "values.name", "values.variables.ip")
 	default:
 		return fmt.Errorf("unknown format: %s", format)
 	}
 }
 
-func outputJSON(data []map[string]interface{}) error {
-	enc := json.NewEncoder(os.Stdout)
+func outputJSON(w io.Writer, data []map[string]interface{}) error {
+	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 	return enc.Encode(data)
 }
 
-func outputINI(data []map[string]interface{}) error {
+func outputINI(w io.Writer, data []map[string]interface{}) error {
+	var out strings.Builder
 	for i, obj := range data {
-		fmt.Printf("[host%d]\n", i)
+		fmt.Fprintf(&out, "[host%d]\n", i)
 		for k, v := range obj {
-			fmt.Printf("%s = %v\n", k, v)
+			fmt.Fprintf(&out, "%s = %v\n", k, v)
 		}
-		fmt.Println()
+		out.WriteString("\n")
 	}
-	return nil
+	_, err := io.WriteString(w, out.String())
+	return err
 }
 
-func outputTXT(data []map[string]interface{}) error {
+func outputTXT(w io.Writer, data []map[string]interface{}) error {
+	var out strings.Builder
 	for i, obj := range data {
-		fmt.Printf("Host %d:\n", i)
+		fmt.Fprintf(&out, "Host %d:\n", i)
 		for k, v := range obj {
-			fmt.Printf("  %s: %v\n", k, v)
+			fmt.Fprintf(&out, "  %s: %v\n", k, v)
 		}
-		fmt.Println()
+		out.WriteString("\n")
 	}
-	return nil
+	_, err := io.WriteString(w, out.String())
+	return err
 }
 
 // --- New Ansible output below ---
@@ -57,6 +61,7 @@ func outputTXT(data []map[string]interface{}) error {
 // OutputAnsibleInventory takes parsed objects and two dot-paths,
 // stripping CIDRs and emitting "hostname ansible_host=IP".
 func OutputAnsibleInventory(
+	w io.Writer,
 	objects []map[string]interface{},
 	hostPath, ipPath string,
 ) error {
@@ -71,7 +76,9 @@ func OutputAnsibleInventory(
 		}
 		// strip CIDR suffix
 		ip := strings.SplitN(ipCIDR, "/", 2)[0]
-		fmt.Printf("%s ansible_host=%s\n", hostname, ip)
+		if _, err := fmt.Fprintf(w, "%s ansible_host=%s\n", hostname, ip); err != nil {
+			return err
+		}
 	}
 	return nil
 }
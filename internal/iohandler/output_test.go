@@ -4,26 +4,20 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
-	"os"
 	"reflect"
 	"testing"
 )
 
-func captureOutput(f func() error) (string, error) {
-	r, w, _ := os.Pipe()
-	orig := os.Stdout
-	os.Stdout = w
-	err := f()
-	w.Close()
-	os.Stdout = orig
+// render runs f against a buffer and returns what it wrote.
+func render(f func(w io.Writer) error) (string, error) {
 	var buf bytes.Buffer
-	io.Copy(&buf, r)
+	err := f(&buf)
 	return buf.String(), err
 }
 
 func TestOutputJSON(t *testing.T) {
 	data := []map[string]interface{}{{"a": "b"}}
-	out, err := captureOutput(func() error { return Output(data, "json") })
+	out, err := render(func(w io.Writer) error { return Output(w, data, "json") })
 	if err != nil {
 		t.Fatalf("Output returned error: %v", err)
 	}
@@ -38,7 +32,7 @@ func TestOutputJSON(t *testing.T) {
 
 func TestOutputINI(t *testing.T) {
 	data := []map[string]interface{}{{"a": "b"}}
-	out, err := captureOutput(func() error { return Output(data, "ini") })
+	out, err := render(func(w io.Writer) error { return Output(w, data, "ini") })
 	if err != nil {
 		t.Fatalf("Output returned error: %v", err)
 	}
@@ -50,7 +44,7 @@ func TestOutputINI(t *testing.T) {
 
 func TestOutputTXT(t *testing.T) {
 	data := []map[string]interface{}{{"a": "b"}}
-	out, err := captureOutput(func() error { return Output(data, "txt") })
+	out, err := render(func(w io.Writer) error { return Output(w, data, "txt") })
 	if err != nil {
 		t.Fatalf("Output returned error: %v", err)
 	}
@@ -68,7 +62,7 @@ func TestOutputAnsible(t *testing.T) {
 		},
 	}
 	data := []map[string]interface{}{obj}
-	out, err := captureOutput(func() error { return Output(data, "ansible") })
+	out, err := render(func(w io.Writer) error { return Output(w, data, "ansible") })
 	if err != nil {
 		t.Fatalf("Output returned error: %v", err)
 	}
@@ -79,7 +73,7 @@ func TestOutputAnsible(t *testing.T) {
 }
 
 func TestOutputUnknown(t *testing.T) {
-	err := Output(nil, "bogus")
+	err := Output(io.Discard, nil, "bogus")
 	if err == nil {
 		t.Fatal("expected error for unknown format")
 	}
@@ -87,9 +81,7 @@ func TestOutputUnknown(t *testing.T) {
 
 func TestOutputAnsibleInventoryError(t *testing.T) {
 	data := []map[string]interface{}{{"a": "b"}}
-	_, err := captureOutput(func() error {
-		return OutputAnsibleInventory(data, "missing", "ip")
-	})
+	err := OutputAnsibleInventory(io.Discard, data, "missing", "ip")
 	if err == nil {
 		t.Fatal("expected error from OutputAnsibleInventory")
 	}
@@ -0,0 +1,61 @@
+package iohandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"gopkg.in/yaml.v3"
+)
+
+// OutputPlaybooks writes the playbook runs of the inventory to w as text
+// lines, a JSON array or a YAML list.
+func OutputPlaybooks(w io.Writer, playbooks []*inventory.Playbook, format string) error {
+	if playbooks == nil {
+		playbooks = []*inventory.Playbook{}
+	}
+	switch format {
+	case "json":
+		return writeJSON(w, playbooks)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(playbooks); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "text":
+		var out strings.Builder
+		for _, p := range playbooks {
+			line, err := formatPlaybook(p)
+			if err != nil {
+				return err
+			}
+			out.WriteString(line)
+		}
+		_, err := io.WriteString(w, out.String())
+		return err
+	default:
+		return fmt.Errorf("unknown playbooks format: %s", format)
+	}
+}
+
+// formatPlaybook renders a playbook run as one line: host, playbook,
+// groups, replayable flag and extra vars as JSON.
+func formatPlaybook(p *inventory.Playbook) (string, error) {
+	fields := []string{p.Host, p.Playbook}
+	if len(p.Groups) > 0 {
+		fields = append(fields, "groups="+strings.Join(p.Groups, ","))
+	}
+	fields = append(fields, fmt.Sprintf("replayable=%t", p.Replayable))
+	if len(p.ExtraVars) > 0 {
+		b, err := json.Marshal(p.ExtraVars)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, "extra_vars="+string(b))
+	}
+	return strings.Join(fields, "\t") + "\n", nil
+}
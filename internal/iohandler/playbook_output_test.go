@@ -0,0 +1,49 @@
+package iohandler
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+func TestOutputPlaybooks(t *testing.T) {
+	playbooks := []*inventory.Playbook{
+		{Playbook: "site.yml", Host: "web1", Groups: []string{"web", "prod"}, ExtraVars: map[string]any{"env": "prod"}, Replayable: true},
+		{Playbook: "db.yml", Host: "db1"},
+	}
+
+	out, err := render(func(w io.Writer) error { return OutputPlaybooks(w, playbooks, "text") })
+	want := "web1\tsite.yml\tgroups=web,prod\treplayable=true\textra_vars={\"env\":\"prod\"}\n" +
+		"db1\tdb.yml\treplayable=false\n"
+	if err != nil || out != want {
+		t.Fatalf("unexpected text output %q (%v)", out, err)
+	}
+
+	out, err = render(func(w io.Writer) error { return OutputPlaybooks(w, playbooks, "json") })
+	if err != nil {
+		t.Fatalf("json output error: %v", err)
+	}
+	var doc []inventory.Playbook
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(doc) != 2 || doc[0].Host != "web1" || doc[0].ExtraVars["env"] != "prod" || doc[1].Replayable {
+		t.Fatalf("unexpected json output: %s", out)
+	}
+
+	out, err = render(func(w io.Writer) error { return OutputPlaybooks(w, playbooks, "yaml") })
+	if err != nil || !strings.Contains(out, "- playbook: site.yml\n  host: web1\n") {
+		t.Fatalf("unexpected yaml output %q (%v)", out, err)
+	}
+
+	out, _ = render(func(w io.Writer) error { return OutputPlaybooks(w, nil, "json") })
+	if out != "[]\n" {
+		t.Fatalf("unexpected empty json output %q", out)
+	}
+	if err := OutputPlaybooks(io.Discard, nil, "bogus"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
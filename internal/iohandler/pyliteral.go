@@ -0,0 +1,321 @@
+package iohandler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// pyNumber matches the Python int and float literals ast.literal_eval
+// accepts, without sign: decimal, hexadecimal, octal and binary integers and
+// floats with optional exponent, all with "_" digit separators.
+var pyNumber = regexp.MustCompile(`^(?:` +
+	`0[xX](?:_?[0-9a-fA-F])+|0[oO](?:_?[0-7])+|0[bB](?:_?[01])+|` +
+	`(?:(?:[0-9](?:_?[0-9])*)?\.[0-9](?:_?[0-9])*|[0-9](?:_?[0-9])*\.?)(?:[eE][+-]?[0-9](?:_?[0-9])*)?` +
+	`)`)
+
+// parsePythonLiteral interprets a host variable of an INI inventory the way
+// Ansible does, with ast.literal_eval: numbers, True, False, None, quoted
+// strings, lists, tuples and dicts are decoded, anything else stays the
+// string it is. Numbers become float64 like the other inventory readers
+// produce, tuples become lists and dict keys are turned into strings.
+func parsePythonLiteral(s string) any {
+	p := &pyParser{s: s}
+	v, err := p.value()
+	if err != nil {
+		return s
+	}
+	p.space()
+	if p.pos != len(p.s) {
+		return s
+	}
+	return v
+}
+
+type pyParser struct {
+	s   string
+	pos int
+}
+
+var errPyLiteral = errors.New("not a python literal")
+
+func (p *pyParser) space() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// accept consumes c after optional whitespace.
+func (p *pyParser) accept(c byte) bool {
+	p.space()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *pyParser) value() (any, error) {
+	p.space()
+	if p.pos >= len(p.s) {
+		return nil, errPyLiteral
+	}
+	switch c := p.s[p.pos]; {
+	case c == '[':
+		p.pos++
+		items, _, err := p.items(']')
+		return items, err
+	case c == '(':
+		p.pos++
+		items, tuple, err := p.items(')')
+		if err != nil {
+			return nil, err
+		}
+		if !tuple && len(items) == 1 {
+			return items[0], nil
+		}
+		return items, nil
+	case c == '{':
+		p.pos++
+		return p.dict()
+	case c == '\'' || c == '"':
+		return p.text()
+	case c == '-' || c == '+':
+		p.pos++
+		p.space()
+		f, err := p.number()
+		if c == '-' {
+			f = -f
+		}
+		return f, err
+	default:
+		for _, kw := range []struct {
+			name string
+			v    any
+		}{{"True", true}, {"False", false}, {"None", nil}} {
+			if p.keyword(kw.name) {
+				return kw.v, nil
+			}
+		}
+		return p.number()
+	}
+}
+
+// keyword consumes the identifier name when it stands on its own.
+func (p *pyParser) keyword(name string) bool {
+	rest := p.s[p.pos:]
+	if !strings.HasPrefix(rest, name) {
+		return false
+	}
+	if len(rest) > len(name) {
+		c := rest[len(name)]
+		if c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	p.pos += len(name)
+	return true
+}
+
+func (p *pyParser) number() (float64, error) {
+	tok := pyNumber.FindString(p.s[p.pos:])
+	if tok == "" {
+		return 0, errPyLiteral
+	}
+	p.pos += len(tok)
+	if p.pos < len(p.s) && (p.s[p.pos] == 'j' || p.s[p.pos] == 'J' || p.s[p.pos] == '_') {
+		return 0, errPyLiteral
+	}
+	digits := strings.ReplaceAll(tok, "_", "")
+	if len(digits) > 1 && digits[0] == '0' && strings.IndexAny(digits, "xXoObB.eE") < 0 {
+		// Python rejects leading zeros in decimal integers other than 0.
+		if strings.Trim(digits, "0") != "" {
+			return 0, errPyLiteral
+		}
+		return 0, nil
+	}
+	if len(digits) > 1 && digits[0] == '0' && strings.IndexAny(digits[1:2], "xXoObB") == 0 {
+		n, err := strconv.ParseUint(digits, 0, 64)
+		if err != nil {
+			return 0, errPyLiteral
+		}
+		return float64(n), nil
+	}
+	f, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, errPyLiteral
+	}
+	return f, nil
+}
+
+// items reads comma separated values up to end and reports whether a comma
+// was seen, which makes a parenthesised value a tuple.
+func (p *pyParser) items(end byte) ([]any, bool, error) {
+	items := []any{}
+	comma := false
+	for {
+		if p.accept(end) {
+			return items, comma, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, false, err
+		}
+		items = append(items, v)
+		if p.accept(',') {
+			comma = true
+			continue
+		}
+		if !p.accept(end) {
+			return nil, false, errPyLiteral
+		}
+		return items, comma, nil
+	}
+}
+
+func (p *pyParser) dict() (map[string]any, error) {
+	m := make(map[string]any)
+	for {
+		if p.accept('}') {
+			return m, nil
+		}
+		k, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(':') {
+			return nil, errPyLiteral
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		switch x := k.(type) {
+		case []any, map[string]any:
+			return nil, errPyLiteral
+		case string:
+			m[x] = v
+		default:
+			m[formatINIKey(x)] = v
+		}
+		if p.accept(',') {
+			continue
+		}
+		if !p.accept('}') {
+			return nil, errPyLiteral
+		}
+		return m, nil
+	}
+}
+
+// formatINIKey renders a non-string dict key the way Python prints it.
+func formatINIKey(k any) string {
+	switch x := k.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if x {
+			return "True"
+		}
+		return "False"
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(k)
+}
+
+// text reads one or more adjacent string literals, which Python
+// concatenates.
+func (p *pyParser) text() (string, error) {
+	var b strings.Builder
+	for {
+		s, err := p.str()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s)
+		p.space()
+		if p.pos >= len(p.s) || (p.s[p.pos] != '\'' && p.s[p.pos] != '"') {
+			return b.String(), nil
+		}
+	}
+}
+
+// str reads a single or double quoted string literal, triple quoted ones
+// included, and resolves its backslash escapes.
+func (p *pyParser) str() (string, error) {
+	q := p.s[p.pos : p.pos+1]
+	if strings.HasPrefix(p.s[p.pos:], q+q+q) {
+		q += q + q
+	}
+	p.pos += len(q)
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		if strings.HasPrefix(p.s[p.pos:], q) {
+			p.pos += len(q)
+			return b.String(), nil
+		}
+		c := p.s[p.pos]
+		if c == '\n' && len(q) == 1 {
+			return "", errPyLiteral
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if p.pos+1 >= len(p.s) {
+			return "", errPyLiteral
+		}
+		if err := p.escape(&b); err != nil {
+			return "", err
+		}
+	}
+	return "", errPyLiteral
+}
+
+// escape resolves the backslash escape at the current position.
+func (p *pyParser) escape(b *strings.Builder) error {
+	c := p.s[p.pos+1]
+	p.pos += 2
+	if r, ok := map[byte]string{
+		'\n': "", '\\': `\`, '\'': "'", '"': `"`,
+		'a': "\a", 'b': "\b", 'f': "\f", 'n': "\n", 'r': "\r", 't': "\t", 'v': "\v",
+	}[c]; ok {
+		b.WriteString(r)
+		return nil
+	}
+	hex := map[byte]int{'x': 2, 'u': 4, 'U': 8}
+	if n, ok := hex[c]; ok {
+		if p.pos+n > len(p.s) {
+			return errPyLiteral
+		}
+		code, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil || code > utf8.MaxRune {
+			return errPyLiteral
+		}
+		p.pos += n
+		b.WriteRune(rune(code))
+		return nil
+	}
+	if c >= '0' && c <= '7' {
+		end := p.pos - 1
+		for end < len(p.s) && end < p.pos+2 && p.s[end] >= '0' && p.s[end] <= '7' {
+			end++
+		}
+		code, _ := strconv.ParseUint(p.s[p.pos-1:end], 8, 32)
+		p.pos = end
+		b.WriteRune(rune(code))
+		return nil
+	}
+	if c == 'N' {
+		return errPyLiteral
+	}
+	// Unknown escapes keep their backslash.
+	b.WriteByte('\\')
+	b.WriteByte(c)
+	return nil
+}
@@ -0,0 +1,54 @@
+package iohandler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePythonLiteral(t *testing.T) {
+	tests := []struct {
+		in   string
+		want any
+	}{
+		{"True", true},
+		{"False", false},
+		{"None", nil},
+		{"42", 42.0},
+		{"-7", -7.0},
+		{"1_000", 1000.0},
+		{"0x1f", 31.0},
+		{"0o17", 15.0},
+		{"0b101", 5.0},
+		{"0", 0.0},
+		{"1.5e3", 1500.0},
+		{".5", 0.5},
+		{`'it''s'`, "its"},
+		{`"a\tb\x41é"`, "a\tbAé"},
+		{`'\d'`, `\d`},
+		{"[1, 'two', [3]]", []any{1.0, "two", []any{3.0}}},
+		{"(1, 2)", []any{1.0, 2.0}},
+		{"(1,)", []any{1.0}},
+		{"()", []any{}},
+		{"(5)", 5.0},
+		{"{'a': 1, 2: [True]}", map[string]any{"a": 1.0, "2": []any{true}}},
+		{"{}", map[string]any{}},
+
+		// not literals, kept as strings
+		{"true", "true"},
+		{"null", "null"},
+		{"007", "007"},
+		{"10.0.0.1", "10.0.0.1"},
+		{"1j", "1j"},
+		{"Trueish", "Trueish"},
+		{"{1, 2}", "{1, 2}"},
+		{"[1, 2", "[1, 2"},
+		{"'open", "'open"},
+		{"a b", "a b"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := parsePythonLiteral(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
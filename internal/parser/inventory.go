@@ -2,6 +2,7 @@ package parser
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 
 	"github.com/bcicen/jstream"
@@ -20,10 +21,28 @@ func ParseInventory(data []byte) (*inventory.Inventory, error) {
 
 // ParseInventoryReader streams Terraform state JSON from r and extracts
 // ansible_* resources to build an inventory compatible with the
-// ansible/ansible provider.
+// ansible/ansible provider. Both the `terraform show -json` layout and raw
+// terraform.tfstate files (state format version 4) are understood.
 func ParseInventoryReader(r io.Reader) (*inventory.Inventory, error) {
+	return ParseInventoryReaderWithOptions(r, Options{})
+}
+
+// Options adjusts how Terraform state is turned into an inventory.
+type Options struct {
+	// Source names the input the state is read from. It is recorded as the
+	// "source" entry of Host.Metadata.
+	Source string
+	// Rules build hosts from resources of other types, such as
+	// aws_instance. The first rule listing a resource's type is used.
+	Rules []Rule
+}
+
+// ParseInventoryReaderWithOptions works like ParseInventoryReader and applies
+// opts while building the inventory.
+func ParseInventoryReaderWithOptions(r io.Reader, opts Options) (*inventory.Inventory, error) {
 	inv := inventory.New()
 	dec := jstream.NewDecoder(r, -1)
+	var playbooks []*inventory.Playbook
 
 	for mv := range dec.Stream() {
 		if mv.ValueType != jstream.Object {
@@ -33,32 +52,17 @@ func ParseInventoryReader(r io.Reader) (*inventory.Inventory, error) {
 		if !ok {
 			continue
 		}
-		t, _ := obj["type"].(string)
-		values, _ := obj["values"].(map[string]interface{})
-
-		switch t {
-		case "ansible_host":
-			h := &inventory.Host{
-				Name:      getString(values["name"]),
-				Groups:    toStringSlice(values["groups"]),
-				Variables: toStringMap(values["variables"]),
-				Enabled:   true,
+		if mv.Depth == 0 {
+			if err := checkStateVersion(obj); err != nil {
+				return nil, err
 			}
-			if en, ok := values["enabled"].(bool); ok {
-				h.Enabled = en
-			}
-			inv.AddHost(h)
-		case "ansible_group":
-			g := &inventory.Group{
-				Name:      getString(values["name"]),
-				Children:  toStringSlice(values["children"]),
-				Variables: toStringMap(values["variables"]),
-				Hosts:     toStringSlice(values["hosts"]),
-				Parents:   toStringSlice(values["parents"]),
+		}
+		for _, res := range resourcesOf(obj) {
+			if res.Type == "ansible_playbook" {
+				playbooks = append(playbooks, playbookOf(res, opts))
+				continue
 			}
-			inv.AddGroup(g)
-		case "ansible_inventory":
-			inv.AddVars(toStringMap(values["variables"]))
+			addResource(inv, res, opts)
 		}
 	}
 
@@ -66,9 +70,92 @@ func ParseInventoryReader(r io.Reader) (*inventory.Inventory, error) {
 		return nil, err
 	}
 
+	// A playbook run may come before the ansible_host of its host, e.g. when
+	// the host is declared in a child module. Runs are attached once every
+	// host is known, so they never stand in for the host's own definition.
+	for _, p := range playbooks {
+		inv.AddPlaybook(p)
+	}
 	return inv, nil
 }
 
+// checkStateVersion rejects raw state files in a format other than version 4.
+// Documents produced by `terraform show -json` carry "format_version" instead
+// and are always accepted.
+func checkStateVersion(root map[string]interface{}) error {
+	if _, ok := root["format_version"]; ok {
+		return nil
+	}
+	v, ok := root["version"].(float64)
+	if !ok {
+		return nil
+	}
+	if v != 4 {
+		return fmt.Errorf("unsupported Terraform state version %v: only version 4 state files are supported", v)
+	}
+	return nil
+}
+
+func addResource(inv *inventory.Inventory, res resource, opts Options) {
+	values := res.Values
+	switch res.Type {
+	case "ansible_host":
+		h := &inventory.Host{
+			Name:      getString(values["name"]),
+			Groups:    toStringSlice(values["groups"]),
+			Variables: toVarMap(values["variables"]),
+			Enabled:   true,
+			Metadata:  res.metadata(opts.Source),
+		}
+		if en, ok := values["enabled"].(bool); ok {
+			h.Enabled = en
+		}
+		h.Sensitive = res.sensitiveVars("variables", h.Variables)
+		inv.AddHost(h)
+	case "ansible_group":
+		g := &inventory.Group{
+			Name:      getString(values["name"]),
+			Children:  toStringSlice(values["children"]),
+			Variables: toVarMap(values["variables"]),
+			Hosts:     toStringSlice(values["hosts"]),
+			Parents:   toStringSlice(values["parents"]),
+		}
+		g.Sensitive = res.sensitiveVars("variables", g.Variables)
+		inv.AddGroup(g)
+	case "ansible_inventory":
+		vars := toVarMap(values["variables"])
+		inv.AddVars(vars)
+		for k := range res.sensitiveVars("variables", vars) {
+			inv.MarkSensitive(k)
+		}
+	default:
+		for _, rule := range opts.Rules {
+			if rule.matches(res.Type) {
+				applyRule(inv, rule, res, opts)
+				break
+			}
+		}
+	}
+}
+
+// playbookOf builds the playbook run of an ansible_playbook resource.
+func playbookOf(res resource, opts Options) *inventory.Playbook {
+	values := res.Values
+	p := &inventory.Playbook{
+		Playbook:   getString(values["playbook"]),
+		Host:       getString(values["name"]),
+		Groups:     toStringSlice(values["groups"]),
+		ExtraVars:  toVarMap(values["extra_vars"]),
+		Replayable: true,
+		Metadata:   res.metadata(opts.Source),
+	}
+	if r, ok := values["replayable"].(bool); ok {
+		p.Replayable = r
+	}
+	p.Sensitive = res.sensitiveVars("extra_vars", p.ExtraVars)
+	return p
+}
+
 func getString(v interface{}) string {
 	if s, ok := v.(string); ok {
 		return s
@@ -90,16 +177,17 @@ func toStringSlice(v interface{}) []string {
 	return out
 }
 
-func toStringMap(v interface{}) map[string]string {
+// toVarMap converts a decoded "variables" attribute into a variable map.
+// Values keep the type they were decoded with, so numbers, booleans, lists
+// and nested maps are preserved alongside plain strings.
+func toVarMap(v interface{}) map[string]any {
 	m, ok := v.(map[string]interface{})
 	if !ok {
 		return nil
 	}
-	out := make(map[string]string, len(m))
+	out := make(map[string]any, len(m))
 	for k, val := range m {
-		if s, ok := val.(string); ok {
-			out[k] = s
-		}
+		out[k] = val
 	}
 	return out
 }
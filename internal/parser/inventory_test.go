@@ -142,3 +142,154 @@ func TestParseInventoryReaderMalformed(t *testing.T) {
 		t.Fatal("expected error for malformed JSON")
 	}
 }
+
+func TestParseTypedVariables(t *testing.T) {
+	data := []byte(`{"type":"ansible_host","values":{"name":"h1","variables":{
+		"port": 2222, "primary": true, "tags": ["a","b"], "labels": {"team":"ops"}, "os": "linux"}}}`)
+	inv, err := ParseInventory(data)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	vars := inv.Hosts["h1"].Variables
+	if vars["port"] != float64(2222) || vars["primary"] != true || vars["os"] != "linux" {
+		t.Fatalf("scalar variables not preserved: %#v", vars)
+	}
+	if tags, ok := vars["tags"].([]any); !ok || len(tags) != 2 {
+		t.Fatalf("list variable not preserved: %#v", vars["tags"])
+	}
+	if labels, ok := vars["labels"].(map[string]any); !ok || labels["team"] != "ops" {
+		t.Fatalf("map variable not preserved: %#v", vars["labels"])
+	}
+}
+
+const rawState = `{
+  "version": 4,
+  "terraform_version": "1.7.5",
+  "serial": 3,
+  "lineage": "0f6c7b3e",
+  "outputs": {},
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "ansible_group",
+      "name": "web",
+      "provider": "provider[\"registry.terraform.io/ansible/ansible\"]",
+      "instances": [
+        {"schema_version": 0, "attributes": {"id": "web", "name": "web", "children": null, "variables": {"tier": "frontend"}}}
+      ]
+    },
+    {
+      "module": "module.app",
+      "mode": "managed",
+      "type": "ansible_host",
+      "name": "node",
+      "provider": "provider[\"registry.terraform.io/ansible/ansible\"]",
+      "instances": [
+        {"index_key": 0, "schema_version": 0, "attributes": {"id": "web0", "name": "web0", "groups": ["web"], "variables": {"ip": "10.0.0.10"}}},
+        {"index_key": 1, "schema_version": 0, "attributes": {"id": "web1", "name": "web1", "groups": ["web"], "variables": {"ip": "10.0.0.11"}}}
+      ]
+    },
+    {
+      "mode": "managed",
+      "type": "ansible_host",
+      "name": "db",
+      "provider": "provider[\"registry.terraform.io/ansible/ansible\"]",
+      "instances": [
+        {"index_key": "primary", "schema_version": 0, "attributes": {"id": "db-primary", "name": "db-primary", "groups": ["db"], "variables": null}}
+      ]
+    },
+    {
+      "mode": "data",
+      "type": "ansible_host",
+      "name": "ignored",
+      "instances": [
+        {"schema_version": 0, "attributes": {"name": "ignored"}}
+      ]
+    }
+  ]
+}`
+
+func TestParseRawState(t *testing.T) {
+	inv, err := ParseInventory([]byte(rawState))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if len(inv.Hosts) != 3 {
+		t.Fatalf("expected 3 hosts, got %d: %v", len(inv.Hosts), inv.Hosts)
+	}
+	if inv.Hosts["web1"].Variables["ip"] != "10.0.0.11" {
+		t.Fatalf("count instance not parsed: %#v", inv.Hosts["web1"])
+	}
+	if _, ok := inv.Hosts["db-primary"]; !ok {
+		t.Fatalf("for_each instance missing")
+	}
+	if _, ok := inv.Hosts["ignored"]; ok {
+		t.Fatalf("data source should be ignored")
+	}
+	if inv.Groups["web"].Variables["tier"] != "frontend" || len(inv.Groups["web"].Hosts) != 2 {
+		t.Fatalf("group not parsed: %#v", inv.Groups["web"])
+	}
+}
+
+func TestParsePlaybookBeforeHost(t *testing.T) {
+	data := []byte(`{"format_version":"1.0","values":{"root_module":{
+		"resources":[
+			{"address":"ansible_playbook.site","type":"ansible_playbook","name":"site",
+			 "values":{"playbook":"site.yml","name":"web1","groups":["deploy"]}}],
+		"child_modules":[{"address":"module.hosts","resources":[
+			{"address":"module.hosts.ansible_host.web1","type":"ansible_host","name":"web1",
+			 "values":{"name":"web1","groups":["web"],"enabled":false}}]}]}}}`)
+	inv, err := ParseInventory(data)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	h := inv.Hosts["web1"]
+	if h == nil || h.Enabled {
+		t.Fatalf("a playbook run must not enable its host: %+v", h)
+	}
+	if h.Metadata[MetaAddress] != "module.hosts.ansible_host.web1" {
+		t.Errorf("host metadata taken from the playbook run: %v", h.Metadata)
+	}
+	if len(h.Groups) != 2 {
+		t.Errorf("expected the host's and the run's groups, got %v", h.Groups)
+	}
+}
+
+func TestParseRawStateUnsupportedVersion(t *testing.T) {
+	data := []byte(`{"version": 3, "modules": [{"path": ["root"], "resources": {}}]}`)
+	if _, err := ParseInventory(data); err == nil {
+		t.Fatal("expected error for state version 3")
+	}
+}
+
+func TestParsePlaybook(t *testing.T) {
+	data := []byte(`{"format_version":"1.0","values":{"root_module":{"resources":[
+		{"address":"ansible_playbook.site","type":"ansible_playbook","name":"site",
+		 "values":{"playbook":"site.yml","name":"web1","groups":["web"],"extra_vars":{"env":"prod"},"replayable":false}},
+		{"address":"ansible_playbook.db","type":"ansible_playbook","name":"db",
+		 "values":{"playbook":"db.yml","name":"db1"}}]}}}`)
+	inv, err := ParseInventory(data)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if len(inv.Playbooks) != 2 {
+		t.Fatalf("expected two playbook runs, got %d", len(inv.Playbooks))
+	}
+	site := inv.Playbooks[0]
+	if site.Playbook != "site.yml" || site.Host != "web1" || site.Replayable || site.ExtraVars["env"] != "prod" ||
+		len(site.Groups) != 1 || site.Metadata[MetaAddress] != "ansible_playbook.site" {
+		t.Fatalf("unexpected playbook: %+v", site)
+	}
+	if !inv.Playbooks[1].Replayable {
+		t.Errorf("replayable should default to true")
+	}
+	if h := inv.Hosts["web1"]; h == nil || !h.Enabled || len(h.Groups) != 1 || h.Groups[0] != "web" {
+		t.Fatalf("playbook host not added: %+v", h)
+	}
+	if g := inv.Groups["web"]; g == nil || len(g.Hosts) != 1 {
+		t.Fatalf("playbook group not added: %+v", g)
+	}
+	if _, ok := inv.Hosts["db1"]; !ok {
+		t.Fatalf("playbook host db1 not added")
+	}
+}
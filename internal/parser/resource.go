@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keys of the provenance entries recorded in inventory.Host.Metadata.
+const (
+	MetaAddress  = "address"
+	MetaModule   = "module"
+	MetaIndex    = "index"
+	MetaProvider = "provider"
+	MetaSource   = "source"
+)
+
+// resource is a single resource instance, normalised from either state
+// layout.
+type resource struct {
+	Address  string
+	Module   string
+	Type     string
+	Name     string
+	Index    interface{}
+	Provider string
+	Values   map[string]interface{}
+	// Sensitive mirrors Values with true at every value Terraform marked
+	// as sensitive.
+	Sensitive interface{}
+}
+
+// resourcesOf returns the resource instances described by obj. Objects from
+// `terraform show -json` carry their attributes in "values"; raw state files
+// list one "attributes" object per entry in "instances", one for each
+// count or for_each index_key.
+func resourcesOf(obj map[string]interface{}) []resource {
+	t, _ := obj["type"].(string)
+	if t == "" {
+		return nil
+	}
+	// Data sources read existing objects and never define inventory hosts,
+	// in either state layout.
+	if mode, _ := obj["mode"].(string); mode == "data" {
+		return nil
+	}
+	name := getString(obj["name"])
+	if values, ok := obj["values"].(map[string]interface{}); ok {
+		address := getString(obj["address"])
+		return []resource{{
+			Address:   address,
+			Module:    moduleOf(address),
+			Type:      t,
+			Name:      name,
+			Index:     obj["index"],
+			Provider:  getString(obj["provider_name"]),
+			Values:    values,
+			Sensitive: obj["sensitive_values"],
+		}}
+	}
+	instances, ok := obj["instances"].([]interface{})
+	if !ok {
+		return nil
+	}
+	module := getString(obj["module"])
+	provider := providerName(getString(obj["provider"]))
+	out := make([]resource, 0, len(instances))
+	for _, i := range instances {
+		inst, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		attrs, ok := inst["attributes"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		res := resource{
+			Module:    module,
+			Type:      t,
+			Name:      name,
+			Index:     inst["index_key"],
+			Provider:  provider,
+			Values:    attrs,
+			Sensitive: sensitiveMarks(inst["sensitive_attributes"]),
+		}
+		res.Address = res.buildAddress()
+		out = append(out, res)
+	}
+	return out
+}
+
+// metadata returns the provenance of the resource for Host.Metadata. Empty
+// entries are left out.
+func (r resource) metadata(source string) map[string]string {
+	md := make(map[string]string)
+	set := func(k, v string) {
+		if v != "" {
+			md[k] = v
+		}
+	}
+	set(MetaAddress, r.Address)
+	set(MetaModule, r.Module)
+	if r.Index != nil {
+		set(MetaIndex, fmt.Sprint(r.Index))
+	}
+	set(MetaProvider, r.Provider)
+	set(MetaSource, source)
+	return md
+}
+
+// buildAddress assembles the resource address the way Terraform prints it,
+// e.g. module.app.ansible_host.web[0] or ansible_host.db["primary"].
+func (r resource) buildAddress() string {
+	addr := r.Type + "." + r.Name
+	if r.Module != "" {
+		addr = r.Module + "." + addr
+	}
+	switch idx := r.Index.(type) {
+	case nil:
+	case string:
+		addr += fmt.Sprintf("[%q]", idx)
+	default:
+		addr += fmt.Sprintf("[%v]", idx)
+	}
+	return addr
+}
+
+// moduleOf returns the module path of a resource address, e.g.
+// module.app.module.web for module.app.module.web.ansible_host.node[0].
+func moduleOf(address string) string {
+	var parts []string
+	rest := address
+	for strings.HasPrefix(rest, "module.") {
+		seg := rest[len("module."):]
+		end := strings.IndexByte(seg, '.')
+		if end < 0 {
+			break
+		}
+		// module instance keys may contain dots inside brackets
+		if open := strings.IndexByte(seg, '['); open >= 0 && open < end {
+			if cl := strings.Index(seg[open:], "]"); cl >= 0 {
+				end = open + cl + 1
+			}
+		}
+		parts = append(parts, "module."+seg[:end])
+		rest = strings.TrimPrefix(seg[end:], ".")
+	}
+	return strings.Join(parts, ".")
+}
+
+// providerName extracts the provider source address from a raw state
+// provider reference such as provider["registry.terraform.io/ansible/ansible"].
+func providerName(ref string) string {
+	if start := strings.Index(ref, `["`); start >= 0 {
+		if end := strings.Index(ref[start:], `"]`); end >= 0 {
+			return ref[start+2 : start+end]
+		}
+	}
+	return ref
+}
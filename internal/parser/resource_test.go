@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMetadataRawState(t *testing.T) {
+	inv, err := ParseInventoryReaderWithOptions(bytes.NewReader([]byte(rawState)), Options{Source: "apps.tfstate"})
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	md := inv.Hosts["web1"].Metadata
+	want := map[string]string{
+		MetaAddress:  "module.app.ansible_host.node[1]",
+		MetaModule:   "module.app",
+		MetaIndex:    "1",
+		MetaProvider: "registry.terraform.io/ansible/ansible",
+		MetaSource:   "apps.tfstate",
+	}
+	for k, v := range want {
+		if md[k] != v {
+			t.Errorf("metadata %s = %q, want %q", k, md[k], v)
+		}
+	}
+	if got := inv.Hosts["db-primary"].Metadata[MetaAddress]; got != `ansible_host.db["primary"]` {
+		t.Errorf("unexpected for_each address %q", got)
+	}
+	if _, ok := inv.Hosts["db-primary"].Metadata[MetaModule]; ok {
+		t.Errorf("root module resources should have no module metadata")
+	}
+}
+
+func TestMetadataShowJSON(t *testing.T) {
+	data := []byte(`{"format_version":"1.0","values":{"root_module":{"child_modules":[{"address":"module.app","child_modules":[{"address":"module.app.module.web","resources":[
+		{"address":"module.app.module.web.ansible_host.node[\"a\"]","mode":"managed","type":"ansible_host","name":"node","index":"a",
+		 "provider_name":"registry.terraform.io/ansible/ansible","values":{"name":"node-a"}}]}]}]}}}`)
+	inv, err := ParseInventory(data)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	md := inv.Hosts["node-a"].Metadata
+	if md[MetaModule] != "module.app.module.web" || md[MetaIndex] != "a" || md[MetaProvider] != "registry.terraform.io/ansible/ansible" {
+		t.Fatalf("unexpected metadata: %#v", md)
+	}
+	if _, ok := md[MetaSource]; ok {
+		t.Fatalf("source should be empty without options: %#v", md)
+	}
+}
+
+func TestResourcesOfSkipsDataSources(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"show-json": {"address": "data.ansible_host.ro", "mode": "data", "type": "ansible_host", "name": "ro",
+			"values": map[string]interface{}{"name": "ro"}},
+		"raw state": {"mode": "data", "type": "ansible_host", "name": "ro",
+			"instances": []interface{}{map[string]interface{}{"attributes": map[string]interface{}{"name": "ro"}}}},
+	}
+	for format, obj := range cases {
+		if got := resourcesOf(obj); len(got) != 0 {
+			t.Errorf("%s: data source returned %#v", format, got)
+		}
+		obj["mode"] = "managed"
+		if got := resourcesOf(obj); len(got) != 1 {
+			t.Errorf("%s: managed resource returned %#v", format, got)
+		}
+	}
+}
+
+func TestModuleOf(t *testing.T) {
+	cases := map[string]string{
+		"ansible_host.web":                               "",
+		"module.app.ansible_host.web[0]":                 "module.app",
+		"module.app.module.web.ansible_host.web":         "module.app.module.web",
+		`module.app["eu.west"].module.db.ansible_host.x`: `module.app["eu.west"].module.db`,
+	}
+	for in, want := range cases {
+		if got := moduleOf(in); got != want {
+			t.Errorf("moduleOf(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestProviderName(t *testing.T) {
+	if got := providerName(`provider["registry.terraform.io/ansible/ansible"]`); got != "registry.terraform.io/ansible/ansible" {
+		t.Fatalf("unexpected provider %q", got)
+	}
+	if got := providerName(`module.app.provider["registry.terraform.io/ansible/ansible"].alias`); got != "registry.terraform.io/ansible/ansible" {
+		t.Fatalf("unexpected aliased provider %q", got)
+	}
+}
@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+// Rule turns resources other than the ansible/ansible ones into hosts. The
+// fields name dot paths into the resource, whose top-level keys are type,
+// name, address, module, index, provider and values; values holds the
+// resource attributes, e.g. values.tags.Name. Numeric path segments index
+// lists, as in values.network_interface.0.addresses.
+type Rule struct {
+	// Types lists the resource types the rule applies to.
+	Types []string
+	// Name is the path of the host name. Resources without a name are
+	// skipped.
+	Name string
+	// Vars maps host variable names to paths. Missing values are not set;
+	// values Terraform marked as sensitive yield sensitive variables.
+	Vars map[string]string
+	// Groups are paths of group names. A list value adds the host to every
+	// group in it.
+	Groups []string
+}
+
+// matches reports whether the rule applies to resources of type t.
+func (r Rule) matches(t string) bool {
+	for _, typ := range r.Types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRule adds the host rule derives from res. Group names are made safe
+// with inventory.SafeGroupName and declared.
+func applyRule(inv *inventory.Inventory, rule Rule, res resource, opts Options) {
+	fields := res.fields()
+	name, _ := lookupPath(fields, rule.Name)
+	hostName := scalar(name)
+	if hostName == "" {
+		return
+	}
+
+	vars := make(map[string]any, len(rule.Vars))
+	var sensitive map[string]bool
+	for k, path := range rule.Vars {
+		v, ok := lookupPath(fields, path)
+		if !ok || v == nil {
+			continue
+		}
+		vars[k] = v
+		if rest, ok := strings.CutPrefix(path, "values."); ok && isSensitive(res.Sensitive, strings.Split(rest, ".")...) {
+			if sensitive == nil {
+				sensitive = make(map[string]bool)
+			}
+			sensitive[k] = true
+		}
+	}
+	inv.AddHost(&inventory.Host{
+		Name:      hostName,
+		Variables: vars,
+		Enabled:   true,
+		Metadata:  res.metadata(opts.Source),
+		Sensitive: sensitive,
+	})
+
+	for _, path := range rule.Groups {
+		v, ok := lookupPath(fields, path)
+		if !ok {
+			continue
+		}
+		items, isList := v.([]interface{})
+		if !isList {
+			items = []interface{}{v}
+		}
+		for _, item := range items {
+			if g := scalar(item); g != "" {
+				inv.AddGroup(&inventory.Group{
+					Name:  inventory.SafeGroupName(g),
+					Hosts: []string{hostName},
+				})
+			}
+		}
+	}
+}
+
+// fields returns the resource as the map rule paths are resolved against.
+func (r resource) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     r.Type,
+		"name":     r.Name,
+		"address":  r.Address,
+		"module":   r.Module,
+		"index":    r.Index,
+		"provider": r.Provider,
+		"values":   r.Values,
+	}
+}
+
+// lookupPath walks obj along a dot-separated path. Numeric segments index
+// into lists.
+func lookupPath(obj map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = obj
+	for _, p := range strings.Split(path, ".") {
+		switch c := cur.(type) {
+		case map[string]interface{}:
+			v, ok := c[p]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			cur = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// scalar renders strings, numbers and booleans as text; anything else
+// yields "".
+func scalar(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	}
+	return ""
+}
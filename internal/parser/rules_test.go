@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+)
+
+const cloudState = `{"format_version":"1.0","values":{"root_module":{"resources":[
+	{"address":"aws_instance.web[0]","type":"aws_instance","name":"web","index":0,
+	 "values":{"private_ip":"10.0.0.5","tags":{"Name":"web-1","Role":"web"}}},
+	{"address":"aws_instance.untagged","type":"aws_instance","name":"untagged",
+	 "values":{"private_ip":"10.0.0.6","tags":{}}},
+	{"address":"libvirt_domain.db","type":"libvirt_domain","name":"db",
+	 "values":{"name":"db-1","network_interface":[{"addresses":["192.168.122.10"]}],"roles":["db","backup node"]}},
+	{"address":"aws_s3_bucket.logs","type":"aws_s3_bucket","name":"logs","values":{"bucket":"logs"}},
+	{"address":"ansible_host.mgmt","type":"ansible_host","name":"mgmt","values":{"name":"mgmt"}}
+]}}}`
+
+func TestParseRules(t *testing.T) {
+	opts := Options{Rules: []Rule{
+		{
+			Types:  []string{"aws_instance", "hcloud_server"},
+			Name:   "values.tags.Name",
+			Vars:   map[string]string{"ansible_host": "values.private_ip", "missing": "values.nope"},
+			Groups: []string{"values.tags.Role", "type"},
+		},
+		{
+			Types:  []string{"libvirt_domain"},
+			Name:   "values.name",
+			Vars:   map[string]string{"ansible_host": "values.network_interface.0.addresses.0"},
+			Groups: []string{"values.roles"},
+		},
+	}}
+	inv, err := ParseInventoryReaderWithOptions(bytes.NewReader([]byte(cloudState)), opts)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if len(inv.Hosts) != 3 {
+		t.Fatalf("expected web-1, db-1 and mgmt, got %v", inv.Hosts)
+	}
+
+	web := inv.Hosts["web-1"]
+	if web == nil || web.Variables["ansible_host"] != "10.0.0.5" || !web.Enabled {
+		t.Fatalf("unexpected web host: %+v", web)
+	}
+	if _, ok := web.Variables["missing"]; ok {
+		t.Errorf("missing values should not be set")
+	}
+	if web.Metadata[MetaAddress] != "aws_instance.web[0]" {
+		t.Errorf("unexpected metadata: %v", web.Metadata)
+	}
+	for _, g := range []string{"web", "aws_instance"} {
+		if grp := inv.Groups[g]; grp == nil || !grp.Declared || len(grp.Hosts) != 1 || grp.Hosts[0] != "web-1" {
+			t.Errorf("group %s: %+v", g, grp)
+		}
+	}
+
+	if db := inv.Hosts["db-1"]; db == nil || db.Variables["ansible_host"] != "192.168.122.10" {
+		t.Fatalf("unexpected db host: %+v", db)
+	}
+	for _, g := range []string{"db", "backup_node"} {
+		if grp := inv.Groups[g]; grp == nil || len(grp.Hosts) != 1 || grp.Hosts[0] != "db-1" {
+			t.Errorf("group %s: %+v", g, grp)
+		}
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	obj := map[string]interface{}{
+		"values": map[string]interface{}{
+			"list": []interface{}{"a", map[string]interface{}{"b": 1.0}},
+		},
+	}
+	tests := []struct {
+		path string
+		want interface{}
+		ok   bool
+	}{
+		{"values.list.0", "a", true},
+		{"values.list.1.b", 1.0, true},
+		{"values.list.2", nil, false},
+		{"values.list.x", nil, false},
+		{"values.list.0.deeper", nil, false},
+		{"nope", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := lookupPath(obj, tt.path)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("lookupPath(%q) = %v, %v; want %v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package parser
+
+import "strconv"
+
+// sensitiveMarks returns the sensitive markers of a raw state instance in
+// the layout of the sensitive_values of `terraform show -json`: a tree
+// mirroring the attributes with true at every sensitive value. Raw state
+// lists the sensitive values as attribute paths, e.g.
+// [{"type":"get_attr","value":"variables"},{"type":"index","value":{"value":"password","type":"string"}}].
+func sensitiveMarks(paths interface{}) interface{} {
+	list, ok := paths.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil
+	}
+	root := make(map[string]interface{})
+	for _, p := range list {
+		steps, ok := p.([]interface{})
+		if !ok || len(steps) == 0 {
+			continue
+		}
+		cur := root
+		for i, s := range steps {
+			key, ok := pathStep(s)
+			if !ok {
+				break
+			}
+			if i == len(steps)-1 {
+				cur[key] = true
+				break
+			}
+			next, ok := cur[key].(map[string]interface{})
+			if !ok {
+				if cur[key] == true {
+					break
+				}
+				next = make(map[string]interface{})
+				cur[key] = next
+			}
+			cur = next
+		}
+	}
+	return root
+}
+
+// pathStep returns the attribute name or index of a raw state path step.
+func pathStep(step interface{}) (string, bool) {
+	m, ok := step.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	switch m["type"] {
+	case "get_attr":
+		s, ok := m["value"].(string)
+		return s, ok
+	case "index":
+		idx, ok := m["value"].(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		switch v := idx["value"].(type) {
+		case string:
+			return v, true
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		}
+	}
+	return "", false
+}
+
+// isSensitive reports whether the value at path is marked sensitive in
+// marks, either itself, through one of its ancestors or because part of it
+// is sensitive.
+func isSensitive(marks interface{}, path ...string) bool {
+	cur := marks
+	for _, seg := range path {
+		switch c := cur.(type) {
+		case bool:
+			return c
+		case map[string]interface{}:
+			cur = c[seg]
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(c) {
+				return false
+			}
+			cur = c[i]
+		default:
+			return false
+		}
+	}
+	return containsMark(cur)
+}
+
+func containsMark(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case map[string]interface{}:
+		for _, e := range x {
+			if containsMark(e) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, e := range x {
+			if containsMark(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sensitiveVars returns the names of the variables in vars, read from the
+// attribute attr, that are marked sensitive.
+func (r resource) sensitiveVars(attr string, vars map[string]any) map[string]bool {
+	var out map[string]bool
+	for k := range vars {
+		if isSensitive(r.Sensitive, attr, k) {
+			if out == nil {
+				out = make(map[string]bool)
+			}
+			out[k] = true
+		}
+	}
+	return out
+}
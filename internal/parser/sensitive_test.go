@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSensitiveShowJSON(t *testing.T) {
+	data := []byte(`{"format_version":"1.0","values":{"root_module":{"resources":[
+		{"address":"ansible_host.db","type":"ansible_host","name":"db",
+		 "values":{"name":"db1","variables":{"password":"pw","os":"linux"}},
+		 "sensitive_values":{"variables":{"password":true}}},
+		{"address":"ansible_group.db","type":"ansible_group","name":"db",
+		 "values":{"name":"db","variables":{"admin":"root","token":"t"}},
+		 "sensitive_values":{"variables":true}},
+		{"address":"ansible_inventory.all","type":"ansible_inventory","name":"all",
+		 "values":{"variables":{"api_key":"k","env":"prod"}},
+		 "sensitive_values":{"variables":{"api_key":true}}},
+		{"address":"ansible_playbook.site","type":"ansible_playbook","name":"site",
+		 "values":{"playbook":"site.yml","name":"db1","extra_vars":{"db_password":"pw","env":"prod"}},
+		 "sensitive_values":{"extra_vars":{"db_password":true}}}]}}}`)
+	inv, err := ParseInventory(data)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if s := inv.Hosts["db1"].Sensitive; len(s) != 1 || !s["password"] {
+		t.Errorf("unexpected host sensitivity: %v", s)
+	}
+	if s := inv.Groups["db"].Sensitive; len(s) != 2 || !s["admin"] || !s["token"] {
+		t.Errorf("a fully sensitive attribute should mark every variable: %v", s)
+	}
+	if s := inv.Sensitive; len(s) != 1 || !s["api_key"] {
+		t.Errorf("unexpected inventory sensitivity: %v", s)
+	}
+	if s := inv.Playbooks[0].Sensitive; len(s) != 1 || !s["db_password"] {
+		t.Errorf("unexpected extra vars sensitivity: %v", s)
+	}
+}
+
+func TestSensitiveRawState(t *testing.T) {
+	data := []byte(`{"version":4,"resources":[
+		{"mode":"managed","type":"ansible_host","name":"db","provider":"provider[\"registry.terraform.io/ansible/ansible\"]",
+		 "instances":[{"attributes":{"name":"db1","variables":{"password":"pw","os":"linux","nested":"x"}},
+		  "sensitive_attributes":[
+		    [{"type":"get_attr","value":"variables"},{"type":"index","value":{"value":"password","type":"string"}}],
+		    [{"type":"get_attr","value":"variables"},{"type":"index","value":{"value":"nested","type":"string"}}]
+		  ]}]}]}`)
+	inv, err := ParseInventory(data)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if s := inv.Hosts["db1"].Sensitive; len(s) != 2 || !s["password"] || !s["nested"] {
+		t.Fatalf("unexpected host sensitivity: %v", s)
+	}
+}
+
+func TestSensitiveRules(t *testing.T) {
+	data := []byte(`{"format_version":"1.0","values":{"root_module":{"resources":[
+		{"address":"aws_db_instance.main","type":"aws_db_instance","name":"main",
+		 "values":{"identifier":"main","address":"db.example.com","password":"pw"},
+		 "sensitive_values":{"password":true}}]}}}`)
+	opts := Options{Rules: []Rule{{
+		Types: []string{"aws_db_instance"},
+		Name:  "values.identifier",
+		Vars:  map[string]string{"ansible_host": "values.address", "db_password": "values.password"},
+	}}}
+	inv, err := ParseInventoryReaderWithOptions(bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if s := inv.Hosts["main"].Sensitive; len(s) != 1 || !s["db_password"] {
+		t.Fatalf("unexpected sensitivity: %v", s)
+	}
+}
+
+func TestIsSensitive(t *testing.T) {
+	marks := map[string]interface{}{
+		"variables": map[string]interface{}{"a": true, "b": false},
+		"list":      []interface{}{false, map[string]interface{}{"x": true}},
+		"all":       true,
+	}
+	tests := []struct {
+		path []string
+		want bool
+	}{
+		{[]string{"variables", "a"}, true},
+		{[]string{"variables", "b"}, false},
+		{[]string{"variables"}, true},
+		{[]string{"list", "0"}, false},
+		{[]string{"list", "1"}, true},
+		{[]string{"all", "anything", "below"}, true},
+		{[]string{"missing"}, false},
+	}
+	for _, tt := range tests {
+		if got := isSensitive(marks, tt.path...); got != tt.want {
+			t.Errorf("isSensitive(%v) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
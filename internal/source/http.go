@@ -0,0 +1,96 @@
+package source
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// HTTPOptions configures fetching state from a Terraform HTTP backend.
+type HTTPOptions struct {
+	// Username and Password enable basic authentication when Username is set.
+	Username string
+	Password string
+	// Headers are added to the request, each in "Name: value" form.
+	Headers []string
+	// CACert is the path of a PEM bundle used to verify the server.
+	CACert string
+	// ClientCert and ClientKey are paths of a PEM certificate and key
+	// presented to the server for mutual TLS.
+	ClientCert string
+	ClientKey  string
+	// SkipVerify disables server certificate verification.
+	SkipVerify bool
+}
+
+// openHTTP issues a GET request for the state at url and returns the
+// response body. The request, including reading the body, is cancelled after
+// timeout.
+func openHTTP(ctx context.Context, url string, opts HTTPOptions, timeout time.Duration) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state URL %q: %w", url, err)
+	}
+	for _, h := range opts.Headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q: expected \"Name: value\"", h)
+		}
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if opts.Username != "" {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+
+	client, err := httpClient(opts, timeout)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %q: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %q: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func httpClient(opts HTTPOptions, timeout time.Duration) (*http.Client, error) {
+	if opts.CACert == "" && opts.ClientCert == "" && opts.ClientKey == "" && !opts.SkipVerify {
+		return &http.Client{Timeout: timeout}, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: opts.SkipVerify}
+	if opts.CACert != "" {
+		pem, err := os.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", opts.CACert)
+		}
+		cfg.RootCAs = pool
+	}
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, errors.New("client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
@@ -0,0 +1,172 @@
+package source
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const state = `{"version":4,"resources":[]}`
+
+func TestOpenHTTPAuthAndHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "ci" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Workspace") != "prod" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, state)
+	}))
+	defer srv.Close()
+
+	opts := Options{HTTP: HTTPOptions{Username: "ci", Password: "secret", Headers: []string{"X-Workspace: prod"}}}
+	rc, err := Open(context.Background(), srv.URL+"/state", opts)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	defer rc.Close()
+	body, _ := io.ReadAll(rc)
+	if string(body) != state {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	if _, err := Open(context.Background(), srv.URL+"/state", Options{}); err == nil {
+		t.Fatal("expected error without credentials")
+	}
+	opts.HTTP.Headers = []string{"broken"}
+	if _, err := Open(context.Background(), srv.URL+"/state", opts); err == nil {
+		t.Fatal("expected error for malformed header")
+	}
+}
+
+func TestOpenHTTPTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, state)
+	}))
+	defer srv.Close()
+
+	if _, err := Open(context.Background(), srv.URL, Options{}); err == nil {
+		t.Fatal("expected verification error without CA")
+	}
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, ca, "CERTIFICATE", srv.Certificate().Raw)
+	rc, err := Open(context.Background(), srv.URL, Options{HTTP: HTTPOptions{CACert: ca}})
+	if err != nil {
+		t.Fatalf("open with CA: %v", err)
+	}
+	rc.Close()
+
+	rc, err = Open(context.Background(), srv.URL, Options{HTTP: HTTPOptions{SkipVerify: true}})
+	if err != nil {
+		t.Fatalf("open with skip verify: %v", err)
+	}
+	rc.Close()
+}
+
+func TestOpenHTTPClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, cert := clientCertificate(t, dir)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, state)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	defer srv.Close()
+
+	if _, err := Open(context.Background(), srv.URL, Options{HTTP: HTTPOptions{SkipVerify: true}}); err == nil {
+		t.Fatal("expected error without client certificate")
+	}
+	opts := Options{HTTP: HTTPOptions{SkipVerify: true, ClientCert: certFile, ClientKey: keyFile}}
+	rc, err := Open(context.Background(), srv.URL, opts)
+	if err != nil {
+		t.Fatalf("open with client certificate: %v", err)
+	}
+	rc.Close()
+
+	opts.HTTP.ClientKey = ""
+	if _, err := Open(context.Background(), srv.URL, opts); err == nil {
+		t.Fatal("expected error for certificate without key")
+	}
+}
+
+func TestOpenHTTPNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	if _, err := Open(context.Background(), srv.URL, Options{}); err == nil {
+		t.Fatal("expected error for missing state")
+	}
+}
+
+func clientCertificate(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ci"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile, cert
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestOpenHTTPTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	if _, err := Open(context.Background(), srv.URL, Options{Timeout: 50 * time.Millisecond}); err == nil {
+		t.Fatal("expected timeout error")
+	}
+}
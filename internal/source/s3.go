@@ -0,0 +1,112 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultWorkspaceKeyPrefix is the prefix the Terraform S3 backend puts in
+// front of the state key of non-default workspaces.
+const DefaultWorkspaceKeyPrefix = "env:"
+
+// S3Options configures fetching state from S3 or an S3 compatible store.
+type S3Options struct {
+	// Region used for request signing and the default endpoint.
+	Region string
+	// Endpoint overrides the AWS endpoint, e.g. http://minio:9000.
+	Endpoint string
+	// PathStyle addresses the bucket in the path instead of the host name.
+	PathStyle bool
+	// Workspace selects a Terraform workspace; "" and "default" read the key
+	// as given.
+	Workspace string
+	// WorkspaceKeyPrefix is prepended to keys of non-default workspaces,
+	// DefaultWorkspaceKeyPrefix when empty.
+	WorkspaceKeyPrefix string
+	// Credentials sign the request; anonymous requests are sent when no
+	// access key is set.
+	Credentials Credentials
+
+	now func() time.Time
+}
+
+// IsS3 reports whether spec is an s3://bucket/key location.
+func IsS3(spec string) bool {
+	return strings.HasPrefix(spec, "s3://")
+}
+
+// openS3 fetches the object named by an s3://bucket/key spec with client.
+func openS3(ctx context.Context, spec string, opts S3Options, client *http.Client) (io.ReadCloser, error) {
+	u, err := s3URL(spec, opts)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state location %q: %w", spec, err)
+	}
+	req.URL = u
+	if opts.Credentials.AccessKeyID != "" {
+		now := time.Now
+		if opts.now != nil {
+			now = opts.now
+		}
+		signV4(req, opts.Credentials, s3Region(opts), "s3", now())
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %q: %w", spec, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %q: %s", spec, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// s3URL resolves spec into the request URL, honouring the workspace key
+// prefix, endpoint override and addressing style.
+func s3URL(spec string, opts S3Options) (*url.URL, error) {
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(spec, "s3://"), "/")
+	if !ok || bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 location %q: expected s3://bucket/key", spec)
+	}
+	if opts.Workspace != "" && opts.Workspace != "default" {
+		prefix := opts.WorkspaceKeyPrefix
+		if prefix == "" {
+			prefix = DefaultWorkspaceKeyPrefix
+		}
+		key = prefix + "/" + opts.Workspace + "/" + key
+	}
+
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s3Region(opts) + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	base := strings.TrimSuffix(u.Path, "/")
+	if opts.PathStyle {
+		u.Path = base + "/" + bucket + "/" + key
+		u.RawPath = uriEncode(base, false) + "/" + uriEncode(bucket, false) + "/" + uriEncode(key, false)
+	} else {
+		u.Host = bucket + "." + u.Host
+		u.Path = base + "/" + key
+		u.RawPath = uriEncode(base, false) + "/" + uriEncode(key, false)
+	}
+	return u, nil
+}
+
+func s3Region(opts S3Options) string {
+	if opts.Region == "" {
+		return "us-east-1"
+	}
+	return opts.Region
+}
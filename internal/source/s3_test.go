@@ -0,0 +1,89 @@
+package source
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenS3PathStyleWorkspace(t *testing.T) {
+	creds := Credentials{AccessKeyID: "minio", SecretAccessKey: "minio123"}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/tfstate/env%3A/prod/app/terraform.tfstate" {
+			t.Errorf("unexpected path %q", r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// re-sign what arrived on the wire and compare signatures
+		chk, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.EscapedPath(), nil)
+		chk.Header.Set("X-Amz-Content-Sha256", r.Header.Get("X-Amz-Content-Sha256"))
+		signV4(chk, creds, "eu-central-1", "s3", now)
+		if got, want := r.Header.Get("Authorization"), chk.Header.Get("Authorization"); got != want {
+			t.Errorf("signature mismatch:\n got %s\nwant %s", got, want)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, state)
+	}))
+	defer srv.Close()
+
+	opts := Options{S3: S3Options{
+		Region:      "eu-central-1",
+		Endpoint:    srv.URL,
+		PathStyle:   true,
+		Workspace:   "prod",
+		Credentials: creds,
+		now:         func() time.Time { return now },
+	}}
+	rc, err := Open(context.Background(), "s3://tfstate/app/terraform.tfstate", opts)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	defer rc.Close()
+	body, _ := io.ReadAll(rc)
+	if string(body) != state {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestOpenS3Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	opts := Options{S3: S3Options{Endpoint: srv.URL, PathStyle: true}}
+	if _, err := Open(context.Background(), "s3://tfstate/app.tfstate", opts); err == nil {
+		t.Fatal("expected error for forbidden object")
+	}
+	if _, err := Open(context.Background(), "s3://bucket-only", opts); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+}
+
+func TestS3URL(t *testing.T) {
+	cases := []struct {
+		spec string
+		opts S3Options
+		want string
+	}{
+		{"s3://b/k.tfstate", S3Options{}, "https://b.s3.us-east-1.amazonaws.com/k.tfstate"},
+		{"s3://b/dir/k.tfstate", S3Options{Region: "eu-west-1", PathStyle: true}, "https://s3.eu-west-1.amazonaws.com/b/dir/k.tfstate"},
+		{"s3://b/k", S3Options{Workspace: "dev", WorkspaceKeyPrefix: "workspaces"}, "https://b.s3.us-east-1.amazonaws.com/workspaces/dev/k"},
+		{"s3://b/k", S3Options{Workspace: "default"}, "https://b.s3.us-east-1.amazonaws.com/k"},
+		{"s3://b/k", S3Options{Endpoint: "http://minio:9000", PathStyle: true}, "http://minio:9000/b/k"},
+	}
+	for _, c := range cases {
+		u, err := s3URL(c.spec, c.opts)
+		if err != nil {
+			t.Fatalf("s3URL(%q): %v", c.spec, err)
+		}
+		if u.String() != c.want {
+			t.Errorf("s3URL(%q) = %s, want %s", c.spec, u, c.want)
+		}
+	}
+}
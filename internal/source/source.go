@@ -0,0 +1,58 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a remote state request, including reading the body,
+// when Options.Timeout is zero.
+const DefaultTimeout = 60 * time.Second
+
+// Options configures how remote state inputs are fetched.
+type Options struct {
+	HTTP HTTPOptions
+	S3   S3Options
+	// Timeout bounds each remote request including reading the state;
+	// DefaultTimeout when zero.
+	Timeout time.Duration
+}
+
+func (o Options) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return o.Timeout
+}
+
+// IsRemote reports whether spec names a remote state location rather than a
+// local path.
+func IsRemote(spec string) bool {
+	return strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") || IsS3(spec)
+}
+
+// Open returns a reader for the state named by spec: "-" for stdin, an
+// http(s) URL of a Terraform HTTP backend, an s3://bucket/key location, or a
+// local file path. The state is streamed, never read into memory as a whole.
+// Callers must close the returned reader.
+func Open(ctx context.Context, spec string, opts Options) (io.ReadCloser, error) {
+	switch {
+	case spec == "-":
+		return io.NopCloser(os.Stdin), nil
+	case IsS3(spec):
+		return openS3(ctx, spec, opts.S3, &http.Client{Timeout: opts.timeout()})
+	case IsRemote(spec):
+		return openHTTP(ctx, spec, opts.HTTP, opts.timeout())
+	default:
+		f, err := os.Open(spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", spec, err)
+		}
+		return f, nil
+	}
+}
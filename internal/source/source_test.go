@@ -0,0 +1,42 @@
+package source
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsRemote(t *testing.T) {
+	for spec, want := range map[string]bool{
+		"state.json":              false,
+		"-":                       false,
+		"http://state/app":        true,
+		"https://state/app":       true,
+		"dir/https:/not-a-url.js": false,
+	} {
+		if got := IsRemote(spec); got != want {
+			t.Errorf("IsRemote(%q) = %v, want %v", spec, got, want)
+		}
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(state), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	rc, err := Open(context.Background(), path, Options{})
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	defer rc.Close()
+	body, _ := io.ReadAll(rc)
+	if string(body) != state {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if _, err := Open(context.Background(), path+".missing", Options{}); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
@@ -0,0 +1,160 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+// Value is an encrypted variable. It is written as an inline "!vault"
+// scalar in YAML and as {"__ansible_vault": "..."} in JSON, the forms
+// Ansible reads from inventories and dynamic inventory scripts.
+type Value struct {
+	Ciphertext string
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (v Value) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!vault",
+		Style: yaml.LiteralStyle,
+		Value: v.Ciphertext,
+	}, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (v Value) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"__ansible_vault": v.Ciphertext})
+}
+
+// Encrypter selects variables by name or sensitivity and encrypts them.
+type Encrypter struct {
+	// Password is the vault password.
+	Password []byte
+	// Sensitive selects the variables Terraform marked as sensitive.
+	Sensitive bool
+	// Pattern, when set, selects the variables whose name it matches.
+	Pattern *regexp.Regexp
+}
+
+// Selects reports whether the variable name, which is sensitive or not, is
+// to be encrypted.
+func (e *Encrypter) Selects(name string, sensitive bool) bool {
+	return (e.Sensitive && sensitive) || (e.Pattern != nil && e.Pattern.MatchString(name))
+}
+
+// EncryptValue encrypts a variable value. Strings are encrypted as they are;
+// other values are encrypted as their JSON encoding and so decrypt to a
+// string.
+func (e *Encrypter) EncryptValue(v any) (Value, error) {
+	plaintext, ok := v.(string)
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return Value{}, err
+		}
+		plaintext = string(b)
+	}
+	ct, err := Encrypt([]byte(plaintext), e.Password)
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{Ciphertext: ct}, nil
+}
+
+// EncryptInventory replaces the selected host, group and inventory variables
+// and playbook extra vars of inv with encrypted values. Encrypted variables
+// are no longer marked sensitive, as their value is not exposed anymore.
+func (e *Encrypter) EncryptInventory(inv *inventory.Inventory) error {
+	if err := e.encryptVars(inv.Vars, inv.Sensitive); err != nil {
+		return fmt.Errorf("inventory variables: %w", err)
+	}
+	for name, g := range inv.Groups {
+		if err := e.encryptVars(g.Variables, g.Sensitive); err != nil {
+			return fmt.Errorf("group %s: %w", name, err)
+		}
+	}
+	for name, h := range inv.Hosts {
+		if err := e.encryptVars(h.Variables, h.Sensitive); err != nil {
+			return fmt.Errorf("host %s: %w", name, err)
+		}
+	}
+	for _, p := range inv.Playbooks {
+		if err := e.encryptVars(p.ExtraVars, p.Sensitive); err != nil {
+			return fmt.Errorf("playbook %s of host %s: %w", p.Playbook, p.Host, err)
+		}
+	}
+	return nil
+}
+
+func (e *Encrypter) encryptVars(vars map[string]any, sensitive map[string]bool) error {
+	for k, v := range vars {
+		if _, done := v.(Value); done || !e.Selects(k, sensitive[k]) {
+			continue
+		}
+		enc, err := e.EncryptValue(v)
+		if err != nil {
+			return fmt.Errorf("encrypting %s: %w", k, err)
+		}
+		vars[k] = enc
+		delete(sensitive, k)
+	}
+	return nil
+}
+
+// DecryptInventory replaces the encrypted host, group and inventory
+// variables and playbook extra vars of inv with their plaintext and marks
+// them as sensitive, as their value is exposed now.
+func DecryptInventory(inv *inventory.Inventory, password []byte) error {
+	names, err := decryptVars(inv.Vars, password)
+	if err != nil {
+		return fmt.Errorf("inventory variables: %w", err)
+	}
+	inv.Sensitive = inventory.AddSensitive(inv.Sensitive, names)
+	for name, g := range inv.Groups {
+		names, err := decryptVars(g.Variables, password)
+		if err != nil {
+			return fmt.Errorf("group %s: %w", name, err)
+		}
+		g.Sensitive = inventory.AddSensitive(g.Sensitive, names)
+	}
+	for name, h := range inv.Hosts {
+		names, err := decryptVars(h.Variables, password)
+		if err != nil {
+			return fmt.Errorf("host %s: %w", name, err)
+		}
+		h.Sensitive = inventory.AddSensitive(h.Sensitive, names)
+	}
+	for _, p := range inv.Playbooks {
+		names, err := decryptVars(p.ExtraVars, password)
+		if err != nil {
+			return fmt.Errorf("playbook %s of host %s: %w", p.Playbook, p.Host, err)
+		}
+		p.Sensitive = inventory.AddSensitive(p.Sensitive, names)
+	}
+	return nil
+}
+
+// decryptVars decrypts the encrypted values of vars in place and returns
+// their names.
+func decryptVars(vars map[string]any, password []byte) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for k, v := range vars {
+		val, ok := v.(Value)
+		if !ok {
+			continue
+		}
+		plaintext, err := Decrypt(val.Ciphertext, password)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting %s: %w", k, err)
+		}
+		vars[k] = string(plaintext)
+		names[k] = true
+	}
+	return names, nil
+}
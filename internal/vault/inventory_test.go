@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+func TestEncryptInventory(t *testing.T) {
+	inv := inventory.New()
+	inv.AddVars(map[string]any{"env": "prod", "api_token": "t0k3n"})
+	inv.AddGroup(&inventory.Group{Name: "db", Variables: map[string]any{"db_password": "pw", "port": 5432}, Sensitive: map[string]bool{"port": true}})
+	inv.AddHost(&inventory.Host{Name: "web1", Variables: map[string]any{"root_pw": "hunter2", "os": "linux"}, Sensitive: map[string]bool{"root_pw": true}})
+	inv.AddPlaybook(&inventory.Playbook{Playbook: "site.yml", Host: "web1", ExtraVars: map[string]any{"deploy_key": "k", "env": "prod"}, Sensitive: map[string]bool{"deploy_key": true}})
+
+	enc := &Encrypter{Password: []byte("pw"), Sensitive: true, Pattern: regexp.MustCompile(`token|password`)}
+	if err := enc.EncryptInventory(inv); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, v := range map[string]any{
+		"api_token":   inv.Vars["api_token"],
+		"db_password": inv.Groups["db"].Variables["db_password"],
+		"port":        inv.Groups["db"].Variables["port"],
+		"root_pw":     inv.Hosts["web1"].Variables["root_pw"],
+		"deploy_key":  inv.Playbooks[0].ExtraVars["deploy_key"],
+	} {
+		if _, ok := v.(Value); !ok {
+			t.Errorf("%s should be encrypted, got %#v", name, v)
+		}
+	}
+	if inv.Vars["env"] != "prod" || inv.Hosts["web1"].Variables["os"] != "linux" || inv.Playbooks[0].ExtraVars["env"] != "prod" {
+		t.Fatalf("unselected variables should stay plain")
+	}
+	if inv.Hosts["web1"].Sensitive["root_pw"] || inv.Groups["db"].Sensitive["port"] {
+		t.Fatalf("encrypted variables should no longer be marked sensitive")
+	}
+
+	pt, err := Decrypt(inv.Groups["db"].Variables["port"].(Value).Ciphertext, []byte("pw"))
+	if err != nil || string(pt) != "5432" {
+		t.Fatalf("non-string values should be encrypted as JSON, got %q (%v)", pt, err)
+	}
+}
+
+func TestValueEncoding(t *testing.T) {
+	v := Value{Ciphertext: "$ANSIBLE_VAULT;1.1;AES256\n6162\n"}
+
+	out, err := yaml.Marshal(map[string]any{"secret": v})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "secret: !vault |\n    $ANSIBLE_VAULT;1.1;AES256\n    6162\n"; string(out) != want {
+		t.Fatalf("unexpected yaml %q", out)
+	}
+
+	js, err := json.Marshal(map[string]any{"secret": v})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(js), `{"secret":{"__ansible_vault":"$ANSIBLE_VAULT;1.1;AES256\n6162\n"}}`) {
+		t.Fatalf("unexpected json %s", js)
+	}
+}
+
+func TestSelects(t *testing.T) {
+	var none Encrypter
+	if none.Selects("password", true) {
+		t.Fatal("empty encrypter should select nothing")
+	}
+	e := Encrypter{Pattern: regexp.MustCompile(`^secret_`)}
+	if !e.Selects("secret_key", false) || e.Selects("key", true) {
+		t.Fatal("pattern selection mismatch")
+	}
+}
+
+func TestDecryptInventory(t *testing.T) {
+	inv := inventory.New()
+	inv.AddHost(&inventory.Host{Name: "web1", Variables: map[string]any{"root_pw": "hunter2", "port": 22.0}})
+	enc := &Encrypter{Password: []byte("pw"), Pattern: regexp.MustCompile(`.`)}
+	if err := enc.EncryptInventory(inv); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DecryptInventory(inv, []byte("wrong")); err == nil {
+		t.Fatalf("expected wrong password to fail")
+	}
+	if err := DecryptInventory(inv, []byte("pw")); err != nil {
+		t.Fatal(err)
+	}
+	vars := inv.Hosts["web1"].Variables
+	if vars["root_pw"] != "hunter2" || vars["port"] != "22" {
+		t.Fatalf("unexpected plaintext: %#v", vars)
+	}
+	if s := inv.Hosts["web1"].Sensitive; !s["root_pw"] || !s["port"] {
+		t.Fatalf("decrypted values should be marked sensitive: %v", s)
+	}
+}
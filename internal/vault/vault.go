@@ -0,0 +1,165 @@
+// Package vault encrypts and decrypts data in the Ansible Vault 1.1 AES256
+// format, so that secrets can be written into inventories that Ansible
+// decrypts at runtime with the same password.
+//
+// The format derives 80 bytes from the password and a random 32 byte salt
+// with PBKDF2-SHA256 (10000 iterations): an AES-256 key, an HMAC-SHA256 key
+// and a CTR initial counter. The PKCS#7 padded plaintext is encrypted with
+// AES-CTR and authenticated with the HMAC. Salt, HMAC and ciphertext are
+// hex encoded on separate lines, hex encoded once more and wrapped at 80
+// characters below the "$ANSIBLE_VAULT;1.1;AES256" header.
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	header     = "$ANSIBLE_VAULT;1.1;AES256"
+	saltLen    = 32
+	iterations = 10000
+	lineWidth  = 80
+)
+
+// ErrIntegrity is returned by Decrypt when the HMAC does not match, usually
+// because the password is wrong.
+var ErrIntegrity = errors.New("vault: HMAC verification failed, wrong password?")
+
+// Encrypt returns plaintext encrypted with password as an Ansible Vault
+// document, ending in a newline.
+func Encrypt(plaintext, password []byte) (string, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("vault: generating salt: %w", err)
+	}
+	return encrypt(plaintext, password, salt)
+}
+
+func encrypt(plaintext, password, salt []byte) (string, error) {
+	cipherKey, hmacKey, iv, err := deriveKeys(password, salt)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	padded := pad(plaintext, aes.BlockSize)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCTR(block, iv).XORKeyStream(ciphertext, padded)
+
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(ciphertext)
+
+	body := hex.EncodeToString(salt) + "\n" +
+		hex.EncodeToString(mac.Sum(nil)) + "\n" +
+		hex.EncodeToString(ciphertext)
+	encoded := hex.EncodeToString([]byte(body))
+
+	var b strings.Builder
+	b.WriteString(header)
+	b.WriteByte('\n')
+	for len(encoded) > 0 {
+		n := min(lineWidth, len(encoded))
+		b.WriteString(encoded[:n])
+		b.WriteByte('\n')
+		encoded = encoded[n:]
+	}
+	return b.String(), nil
+}
+
+// Decrypt returns the plaintext of an Ansible Vault 1.1 or 1.2 AES256
+// document. The vault ID of a 1.2 header is ignored.
+func Decrypt(vaulttext string, password []byte) ([]byte, error) {
+	lines := strings.Split(strings.TrimSpace(vaulttext), "\n")
+	fields := strings.Split(strings.TrimSpace(lines[0]), ";")
+	if len(fields) < 3 || fields[0] != "$ANSIBLE_VAULT" {
+		return nil, errors.New("vault: missing $ANSIBLE_VAULT header")
+	}
+	if fields[1] != "1.1" && fields[1] != "1.2" {
+		return nil, fmt.Errorf("vault: unsupported format version %s", fields[1])
+	}
+	if fields[2] != "AES256" {
+		return nil, fmt.Errorf("vault: unsupported cipher %s", fields[2])
+	}
+
+	var encoded strings.Builder
+	for _, l := range lines[1:] {
+		encoded.WriteString(strings.TrimSpace(l))
+	}
+	body, err := hex.DecodeString(encoded.String())
+	if err != nil {
+		return nil, fmt.Errorf("vault: malformed payload: %w", err)
+	}
+	parts := strings.Split(string(body), "\n")
+	if len(parts) != 3 {
+		return nil, errors.New("vault: malformed payload")
+	}
+	var decoded [3][]byte
+	for i, p := range parts {
+		if decoded[i], err = hex.DecodeString(p); err != nil {
+			return nil, fmt.Errorf("vault: malformed payload: %w", err)
+		}
+	}
+	salt, sum, ciphertext := decoded[0], decoded[1], decoded[2]
+
+	cipherKey, hmacKey, iv, err := deriveKeys(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(ciphertext)
+	if !hmac.Equal(mac.Sum(nil), sum) {
+		return nil, ErrIntegrity
+	}
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	padded := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, iv).XORKeyStream(padded, ciphertext)
+	return unpad(padded, aes.BlockSize)
+}
+
+// deriveKeys splits the PBKDF2 output into the AES key, the HMAC key and
+// the CTR initial counter.
+func deriveKeys(password, salt []byte) (cipherKey, hmacKey, iv []byte, err error) {
+	key, err := pbkdf2.Key(sha256.New, string(password), salt, iterations, 2*32+aes.BlockSize)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("vault: deriving key: %w", err)
+	}
+	return key[:32], key[32:64], key[64:], nil
+}
+
+// pad applies PKCS#7 padding.
+func pad(data []byte, size int) []byte {
+	n := size - len(data)%size
+	return append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+// unpad removes PKCS#7 padding.
+func unpad(data []byte, size int) ([]byte, error) {
+	if len(data) == 0 || len(data)%size != 0 {
+		return nil, errors.New("vault: invalid padding")
+	}
+	n := int(data[len(data)-1])
+	if n == 0 || n > size || n > len(data) {
+		return nil, errors.New("vault: invalid padding")
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, errors.New("vault: invalid padding")
+		}
+	}
+	return data[:len(data)-n], nil
+}
@@ -0,0 +1,78 @@
+package vault
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// knownVault was produced independently of this package with Python's
+// hashlib and hmac modules and openssl's aes-256-ctr, following the format
+// ansible-vault writes.
+const knownVault = `$ANSIBLE_VAULT;1.1;AES256
+30303031303230333034303530363037303830393061306230633064306530663130313131323133
+3134313531363137313831393161316231633164316531660a656361633232326238623631346231
+38323331626231353539323837623039396662353434313264666231313336316561623935646231
+3632616462663161640a623861303736376165643738653432653030383735303434646361633138
+3539
+`
+
+func TestEncryptKnownAnswer(t *testing.T) {
+	salt := make([]byte, saltLen)
+	for i := range salt {
+		salt[i] = byte(i)
+	}
+	got, err := encrypt([]byte("s3cr3t-p@ss"), []byte("correct horse"), salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != knownVault {
+		t.Fatalf("unexpected vault text:\n%s\nwant:\n%s", got, knownVault)
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	pt, err := Decrypt(knownVault, []byte("correct horse"))
+	if err != nil || string(pt) != "s3cr3t-p@ss" {
+		t.Fatalf("Decrypt = %q, %v", pt, err)
+	}
+	if _, err := Decrypt(knownVault, []byte("wrong")); !errors.Is(err, ErrIntegrity) {
+		t.Fatalf("expected integrity error, got %v", err)
+	}
+	v12 := strings.Replace(knownVault, "1.1;AES256", "1.2;AES256;prod", 1)
+	if pt, err := Decrypt(v12, []byte("correct horse")); err != nil || string(pt) != "s3cr3t-p@ss" {
+		t.Fatalf("Decrypt 1.2 = %q, %v", pt, err)
+	}
+	for _, bad := range []string{"plain text", "$ANSIBLE_VAULT;1.0;AES\n00", "$ANSIBLE_VAULT;1.1;AES256\nzz"} {
+		if _, err := Decrypt(bad, []byte("x")); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, pt := range []string{"", "x", "exactly16bytes!!", strings.Repeat("long secret ", 40)} {
+		vt, err := Encrypt([]byte(pt), []byte("pw"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(vt, "\n"), "\n")
+		if lines[0] != header || !strings.HasSuffix(vt, "\n") {
+			t.Fatalf("unexpected envelope %q", vt)
+		}
+		for _, l := range lines[1:] {
+			if len(l) > lineWidth {
+				t.Fatalf("line longer than %d characters: %q", lineWidth, l)
+			}
+		}
+		got, err := Decrypt(vt, []byte("pw"))
+		if err != nil || string(got) != pt {
+			t.Fatalf("round trip of %q = %q, %v", pt, got, err)
+		}
+	}
+	a, _ := Encrypt([]byte("same"), []byte("pw"))
+	b, _ := Encrypt([]byte("same"), []byte("pw"))
+	if a == b {
+		t.Fatal("salts should differ between encryptions")
+	}
+}
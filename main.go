@@ -1,77 +1,208 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"slices"
 	"strings"
 
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/expr"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
 	"github.com/HilkopterBob/terraform-ansible-inventory/internal/iohandler"
-	"github.com/HilkopterBob/terraform-ansible-inventory/internal/parser"
 	"github.com/urfave/cli/v2"
 )
 
 const version = "v1.0.0"
 
+const (
+	// stateEnv names the environment variable holding the state path when no
+	// --input flag is given.
+	stateEnv = "TF_ANSIBLE_INVENTORY_STATE"
+	// configEnv names the environment variable holding the config file path.
+	configEnv = "TF_ANSIBLE_INVENTORY_CONFIG"
+	// vaultPasswordEnv names the environment variable holding the vault
+	// password when no password file is given.
+	vaultPasswordEnv = "TF_ANSIBLE_INVENTORY_VAULT_PASSWORD"
+)
+
 func main() {
 	app := &cli.App{
-		Name:      "terraform-ansible-inventory",
-		Usage:     "Generate an Ansible inventory from a Terraform state produced by the ansible/ansible provider",
-		Version:   version,
-		ArgsUsage: "--input <file> [--format yaml|ini|json]",
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:     "input",
-				Aliases:  []string{"i"},
-				Usage:    "Path to input JSON file (or '-' for stdin)",
-				Required: true,
-			},
+		Name:    "terraform-ansible-inventory",
+		Usage:   "Generate an Ansible inventory from a Terraform state produced by the ansible/ansible provider",
+		Version: version,
+		// Header values and paths may contain commas; repeat the flag instead.
+		DisableSliceFlagSeparator: true,
+		ArgsUsage:                 "--input <file> [--input <file>...] [--format yaml|ini|json|ansible] | --list | --host <name>",
+		Flags: append(append(inputFlags(), vaultFlags()...),
 			&cli.StringFlag{
 				Name:    "format",
 				Aliases: []string{"f"},
 				Value:   "yaml",
-				Usage:   "Output format: yaml, ini, or json",
+				Usage:   "Output format: yaml, ini, json, or ansible (ansible-inventory --list JSON)",
+			},
+			&cli.BoolFlag{
+				Name:  "tf-metadata",
+				Usage: "Expose each host's Terraform address, module, index, provider and source as tf_* variables",
+			},
+			sensitiveFlag(),
+			&cli.BoolFlag{
+				Name:  "list",
+				Usage: "Print the inventory as an Ansible dynamic inventory script would",
 			},
 			&cli.StringSliceFlag{
 				Name:  "host",
-				Usage: "Only include the specified host(s)",
+				Usage: "Only include the specified host(s); on its own, print the host's variables like a dynamic inventory script",
 			},
 			&cli.StringSliceFlag{
 				Name:  "group",
 				Usage: "Only include hosts belonging to the specified group(s)",
 			},
+			&cli.StringFlag{
+				Name:    "limit",
+				Aliases: []string{"l"},
+				Usage:   "Only include hosts matching an Ansible host pattern, e.g. 'web:&prod:!db*' or '@retry_hosts.txt'",
+			},
+			&cli.StringFlag{
+				Name:  "where",
+				Usage: "Only include hosts whose variables satisfy the expression, e.g. 'os == \"linux\" && region in [\"eu-1\"]'",
+			},
+			&cli.StringFlag{
+				Name:  "host-vars-dir",
+				Usage: "Write every host's variables to DIR/<host>.yml instead of the inventory; with vault encryption, to DIR/<host>/vars.yml and a vault-encrypted DIR/<host>/vault.yml",
+			},
+			&cli.StringFlag{
+				Name:  "inventory-dir",
+				Usage: "Write an inventory directory instead of printing: a hosts file with groups only, host_vars/ and group_vars/",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Write the output to `FILE` instead of stdout; the file is replaced atomically",
+			},
+			&cli.StringFlag{
+				Name:  "output-mode",
+				Value: "0644",
+				Usage: "Permissions of the --output file in octal, e.g. 0600 when it holds secrets",
+			},
+			&cli.StringFlag{
+				Name:  "check",
+				Usage: "Instead of printing, compare the inventory with `FILE` in the chosen format and exit with status 1, printing the differences, when they differ",
+			},
+		),
+		Commands: []*cli.Command{
+			validateCommand(),
+			playbooksCommand(),
+			diffCommand(),
 		},
 		Action: func(c *cli.Context) error {
-			// 1) Read input
-			var data []byte
-			var err error
-			path := c.String("input")
-			if path == "-" {
-				data, err = io.ReadAll(os.Stdin)
-			} else {
-				data, err = os.ReadFile(path)
-			}
+			// 1) Parse and merge the inventories from all Terraform states and
+			//    apply the groups defined in the config file
+			inv, _, err := loadFromContext(c)
 			if err != nil {
-				return fmt.Errorf("failed to read %q: %w", path, err)
+				return err
 			}
 
-			// 2) Parse inventory from Terraform state
-			inv, err := parser.ParseInventory(data)
+			enc, err := encrypterFromContext(c)
+			if err != nil {
+				return err
+			}
+			mode, err := inventory.ParseSensitiveMode(c.String("sensitive"))
 			if err != nil {
 				return err
 			}
+			// Ansible runs --list and --host itself and needs the real
+			// values, so masking there takes an explicit --sensitive.
+			if !c.IsSet("sensitive") && (c.Bool("list") || isHostQuery(c)) {
+				mode = inventory.KeepSensitive
+			}
 
-			// 3) Apply filters
+			// 2) Answer dynamic inventory host queries
 			hosts := c.StringSlice("host")
+			if isHostQuery(c) {
+				redact(inv, mode, enc)
+				if enc != nil {
+					if err := enc.EncryptInventory(inv); err != nil {
+						return err
+					}
+				}
+				return iohandler.OutputDynamicHost(os.Stdout, inv, hosts[0])
+			}
+
+			// 3) Apply filters
 			groups := c.StringSlice("group")
 			if len(hosts) > 0 || len(groups) > 0 {
 				inv = inv.CopyFiltered(hosts, groups)
 			}
+			if c.IsSet("limit") {
+				if inv, err = inv.Limit(c.String("limit")); err != nil {
+					return err
+				}
+			}
+			if c.IsSet("where") {
+				e, err := expr.Parse(c.String("where"))
+				if err != nil {
+					return err
+				}
+				if inv, err = inv.Where(e.Match); err != nil {
+					return err
+				}
+			}
 
-			// 4) Dispatch output
+			// 4) Redact sensitive values, write the inventory directory or
+			//    host_vars and encrypt the selected variables
 			format := strings.ToLower(c.String("format"))
-			return iohandler.OutputInventory(inv, format)
+			check := c.String("check")
+			if check != "" && (c.Bool("list") || c.IsSet("inventory-dir") || c.IsSet("host-vars-dir") || c.IsSet("output")) {
+				return errors.New("--check compares a single inventory file and cannot be combined with --list, --inventory-dir, --host-vars-dir or --output")
+			}
+			if c.IsSet("output") && c.IsSet("inventory-dir") {
+				return errors.New("--output cannot be combined with --inventory-dir")
+			}
+			if !c.Bool("list") && !c.IsSet("inventory-dir") && !slices.Contains([]string{"yaml", "ini", "json", "ansible"}, format) {
+				return fmt.Errorf("unknown inventory format: %s", format)
+			}
+			hostVarsDir := c.String("host-vars-dir")
+			if enc != nil && format == "ini" && !c.Bool("list") && !c.IsSet("inventory-dir") &&
+				encryptsOutput(enc, inv, hostVarsDir == "") {
+				return errors.New("ini output cannot hold vault-encrypted values: use yaml, json or ansible, or write the encrypted host variables with --host-vars-dir")
+			}
+			redact(inv, mode, enc)
+			if dir := c.String("inventory-dir"); dir != "" {
+				stale, err := iohandler.WriteInventoryDir(dir, inv, enc)
+				warnStale(stale)
+				return err
+			}
+			if hostVarsDir != "" {
+				stale, err := iohandler.WriteHostVars(hostVarsDir, inv, enc)
+				if err != nil {
+					return err
+				}
+				warnStale(stale)
+				// Ansible reads the host variables from the directory now.
+				for _, h := range inv.Hosts {
+					h.Variables = map[string]any{}
+					h.Sensitive = nil
+				}
+			}
+			if enc != nil {
+				if err := enc.EncryptInventory(inv); err != nil {
+					return err
+				}
+			}
+
+			// 5) Dispatch output, or compare it with the committed inventory
+			if check != "" {
+				return checkInventory(inv, format, check, enc)
+			}
+			return writeOutput(c, func(w io.Writer) error {
+				if c.Bool("list") {
+					return iohandler.OutputDynamicList(w, inv)
+				}
+				return iohandler.OutputInventory(w, inv, format)
+			})
 		},
 		CustomAppHelpTemplate: `{{.Name}} {{.Version}}
 
@@ -80,6 +211,9 @@ func main() {
 USAGE:
    {{.HelpName}} {{.ArgsUsage}}
 
+COMMANDS:
+{{range .VisibleCommands}}   {{.Name}}{{"\t"}}{{.Usage}}
+{{end}}
 FLAGS:
 {{range .VisibleFlags}}{{.}}
 {{end}}
@@ -88,6 +222,32 @@ EXAMPLES:
    {{.HelpName}} --input terraform_state.json -f yaml
    # INI inventory
    {{.HelpName}} -i terraform_state.json -f ini
+   # Fetch the state from a Terraform HTTP backend
+   {{.HelpName}} -i https://state.example.com/app --http-username ci
+   # Read the state of the prod workspace from S3
+   {{.HelpName}} -i s3://tfstate/app/terraform.tfstate --workspace prod
+   # Only the production web servers, like ansible-playbook --limit
+   {{.HelpName}} -i terraform_state.json --limit 'web:&prod'
+   # Only the Debian hosts in staging
+   {{.HelpName}} -i terraform_state.json --where 'distro == "debian" && env == "staging"'
+   # Encrypt sensitive and password variables with Ansible Vault
+   {{.HelpName}} -i terraform_state.json --vault-sensitive --vault-pattern password --vault-password-file ~/.vault_pass
+   # Write hosts, host_vars/ and group_vars/ for an Ansible repository
+   {{.HelpName}} -i terraform_state.json --inventory-dir inventory/prod
+   # Replace the committed inventory atomically
+   {{.HelpName}} -i terraform_state.json -o inventory/hosts.yml
+   # Fail CI when the committed inventory is out of date
+   {{.HelpName}} -i terraform_state.json -f ini --check inventory/hosts.ini
+   # Add the hand-maintained bare metal hosts
+   {{.HelpName}} -i terraform_state.json --import inventory/bare-metal.ini
+   # Merge the states of several root modules
+   {{.HelpName}} -i network.tfstate -i 'apps/*.tfstate' --on-conflict error
+   # Fail when the inventory has structural problems
+   {{.HelpName}} validate -i terraform_state.json --format json
+   # Inventory impact of a Terraform change as Markdown
+   {{.HelpName}} diff old.tfstate new.tfstate -f markdown
+   # Dynamic inventory for ansible-playbook
+   ` + stateEnv + `=terraform_state.json ansible-playbook -i $(which {{.HelpName}}) site.yml
 `,
 	}
 
@@ -95,3 +255,20 @@ EXAMPLES:
 		log.Fatalf("ERROR: %v\n", err)
 	}
 }
+
+// isHostQuery reports whether the tool was invoked exactly as
+// "--host <name>", which is how Ansible asks a dynamic inventory script for
+// the variables of a single host. Any other command line flag or argument
+// makes --host a filter; settings from the environment don't count.
+func isHostQuery(c *cli.Context) bool {
+	return len(c.StringSlice("host")) == 1 && c.NumFlags() == 1 && c.NArg() == 0
+}
+
+// warnStale reports the variable files an earlier run wrote for hosts and
+// groups that are not part of the inventory any more, or were filtered out.
+// They are kept, as they may still be wanted.
+func warnStale(paths []string) {
+	for _, p := range paths {
+		fmt.Fprintf(os.Stderr, "WARNING: %s belongs to no host or group in this inventory; remove it if it is no longer needed\n", p)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/iohandler"
+	"github.com/urfave/cli/v2"
+)
+
+// writeOutput runs write against stdout or, with --output, against the
+// named file, which is replaced atomically and gets the --output-mode
+// permissions.
+func writeOutput(c *cli.Context, write func(w io.Writer) error) error {
+	path := c.String("output")
+	if path == "" || path == "-" {
+		return write(os.Stdout)
+	}
+	mode, err := strconv.ParseUint(c.String("output-mode"), 8, 32)
+	if err != nil || mode > 0o777 {
+		return fmt.Errorf("invalid --output-mode %q: expected octal permissions such as 0644", c.String("output-mode"))
+	}
+	if err := iohandler.WriteFileAtomic(path, os.FileMode(mode), write); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
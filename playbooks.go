@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/iohandler"
+	"github.com/urfave/cli/v2"
+)
+
+// playbooksCommand lists the playbook runs defined by ansible_playbook
+// resources.
+func playbooksCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "playbooks",
+		Usage: "List the playbook runs defined by ansible_playbook resources",
+		Flags: append(append(inputFlags(), vaultFlags()...),
+			sensitiveFlag(),
+			&cli.StringFlag{
+				Name:    "format",
+				Aliases: []string{"f"},
+				Value:   "text",
+				Usage:   "Output format: text, json or yaml",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			inv, _, err := loadFromContext(c)
+			if err != nil {
+				return err
+			}
+			enc, err := encrypterFromContext(c)
+			if err != nil {
+				return err
+			}
+			mode, err := inventory.ParseSensitiveMode(c.String("sensitive"))
+			if err != nil {
+				return err
+			}
+			redact(inv, mode, enc)
+			if enc != nil {
+				if err := enc.EncryptInventory(inv); err != nil {
+					return err
+				}
+			}
+			return iohandler.OutputPlaybooks(os.Stdout, inv.Playbooks, strings.ToLower(c.String("format")))
+		},
+	}
+}
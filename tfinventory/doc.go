@@ -0,0 +1,23 @@
+// Package tfinventory turns Terraform state into an Ansible inventory. It is
+// the library behind the terraform-ansible-inventory command, for Go
+// programs that want to build, filter and render inventories without
+// running the binary.
+//
+// Parse reads a Terraform state (terraform show -json output or a raw
+// terraform.tfstate file) into an Inventory. Filter, Limit and Where select
+// hosts the way the --host/--group, --limit and --where flags do, Merge and
+// Redact combine and clean inventories, and Encode writes the inventory in
+// one of the Formats.
+//
+// # Compatibility
+//
+// The package follows semantic versioning together with the module. Within
+// a major version the functions, types, fields, constants and variables
+// declared in this package are neither removed nor changed incompatibly.
+// Inventory, Host, Group and Playbook are plain data without methods. Rule,
+// AddressMapping and the AddressFamily, ConflictPolicy and SensitiveMode
+// enumerations are aliases of internal types: their exported fields,
+// constants and String methods are covered the same way. New identifiers,
+// fields and formats may be added in minor releases. Packages below
+// internal/ carry no such guarantee and cannot be imported.
+package tfinventory
@@ -0,0 +1,77 @@
+package tfinventory_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/tfinventory"
+)
+
+const state = `{"format_version": "1.0", "values": {"root_module": {"resources": [
+  {"type": "ansible_group", "values": {"name": "web", "variables": {"tier": "frontend"}}},
+  {"type": "ansible_host", "values": {"name": "web1", "groups": ["web"], "variables": {"ip": "10.0.0.1/24", "os": "linux"}}},
+  {"type": "ansible_host", "values": {"name": "db1", "groups": ["db"], "variables": {"ip": "10.0.0.2/24", "os": "bsd"}}}
+]}}}`
+
+func ExampleParse() {
+	inv, err := tfinventory.Parse(context.Background(), strings.NewReader(state), tfinventory.Options{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := tfinventory.Encode(os.Stdout, inv, "yaml"); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// all:
+	//   children:
+	//     db:
+	//       hosts:
+	//         db1:
+	//           ansible_host: 10.0.0.2
+	//           os: bsd
+	//     web:
+	//       hosts:
+	//         web1:
+	//           ansible_host: 10.0.0.1
+	//           os: linux
+	//       vars:
+	//         tier: frontend
+}
+
+func ExampleWhere() {
+	inv, err := tfinventory.Parse(context.Background(), strings.NewReader(state), tfinventory.Options{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	linux, err := tfinventory.Where(inv, `os == "linux"`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, h := range linux.Hosts {
+		fmt.Println(name, h.Variables["ansible_host"])
+	}
+	// Output:
+	// web1 10.0.0.1
+}
+
+func ExampleLimit() {
+	inv, err := tfinventory.Parse(context.Background(), strings.NewReader(state), tfinventory.Options{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	dbs, err := tfinventory.Limit(inv, "db*")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := tfinventory.Encode(os.Stdout, dbs, "ini"); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// [all]
+	//
+	// [db]
+	// db1 ansible_host=10.0.0.2 os=bsd
+}
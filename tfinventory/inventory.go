@@ -0,0 +1,179 @@
+package tfinventory
+
+import (
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+)
+
+// Inventory is an Ansible inventory. Variables keep the type they have in
+// the Terraform state: strings, float64 numbers, booleans, []any and
+// map[string]any, plus VaultValue for encrypted values read by Decode.
+type Inventory struct {
+	Hosts  map[string]*Host
+	Groups map[string]*Group
+	// Vars are the inventory variables of ansible_inventory resources,
+	// written as the variables of the all group.
+	Vars map[string]any
+	// Sensitive names the inventory variables Terraform marked as
+	// sensitive.
+	Sensitive map[string]bool `json:",omitempty"`
+	// Playbooks are the runs of ansible_playbook resources.
+	Playbooks []*Playbook `json:",omitempty"`
+}
+
+// Host is an inventory host. Metadata records where the host came from, with
+// the keys "address", "module", "index", "provider" and "source". Sensitive
+// names the variables Terraform marked as sensitive. A host that is not
+// Enabled is written with ansible_disabled=true.
+type Host struct {
+	Name      string
+	Variables map[string]any
+	Groups    []string
+	Enabled   bool
+	Metadata  map[string]string
+	Sensitive map[string]bool `json:",omitempty"`
+}
+
+// Group is an inventory group. Hosts and Children list its direct members,
+// Parents the groups it is a child of. Sensitive names the variables
+// Terraform marked as sensitive.
+type Group struct {
+	Name      string
+	Variables map[string]any
+	Children  []string
+	Hosts     []string
+	Parents   []string
+	Sensitive map[string]bool `json:",omitempty"`
+
+	// declared is kept for groups the state or a decoded file defines, as
+	// opposed to groups only named by a host.
+	declared bool
+}
+
+// Playbook is a playbook run defined by an ansible_playbook resource: the
+// provider runs Playbook against Host, which it adds to Groups, passing
+// ExtraVars on the command line. Sensitive names the extra vars Terraform
+// marked as sensitive.
+type Playbook struct {
+	Playbook   string            `json:"playbook" yaml:"playbook"`
+	Host       string            `json:"host" yaml:"host"`
+	Groups     []string          `json:"groups,omitempty" yaml:"groups,omitempty"`
+	ExtraVars  map[string]any    `json:"extra_vars,omitempty" yaml:"extra_vars,omitempty"`
+	Replayable bool              `json:"replayable" yaml:"replayable"`
+	Metadata   map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Sensitive  map[string]bool   `json:"-" yaml:"-"`
+}
+
+// exportInventory returns the public form of in. Both share their maps and
+// slices.
+func exportInventory(in *inventory.Inventory) *Inventory {
+	inv := &Inventory{}
+	inv.set(in)
+	return inv
+}
+
+// set replaces the content of inv with in. Hosts and groups present in both
+// keep their pointers, so callers holding them see the update.
+func (inv *Inventory) set(in *inventory.Inventory) {
+	inv.Vars, inv.Sensitive = in.Vars, in.Sensitive
+
+	hosts := make(map[string]*Host, len(in.Hosts))
+	for name, h := range in.Hosts {
+		p := inv.Hosts[name]
+		if p == nil {
+			p = &Host{}
+		}
+		*p = Host{
+			Name:      h.Name,
+			Variables: h.Variables,
+			Groups:    h.Groups,
+			Enabled:   h.Enabled,
+			Metadata:  h.Metadata,
+			Sensitive: h.Sensitive,
+		}
+		hosts[name] = p
+	}
+	inv.Hosts = hosts
+
+	groups := make(map[string]*Group, len(in.Groups))
+	for name, g := range in.Groups {
+		p := inv.Groups[name]
+		if p == nil {
+			p = &Group{}
+		}
+		*p = Group{
+			Name:      g.Name,
+			Variables: g.Variables,
+			Children:  g.Children,
+			Hosts:     g.Hosts,
+			Parents:   g.Parents,
+			Sensitive: g.Sensitive,
+			declared:  g.Declared,
+		}
+		groups[name] = p
+	}
+	inv.Groups = groups
+
+	inv.Playbooks = nil
+	for _, p := range in.Playbooks {
+		inv.Playbooks = append(inv.Playbooks, &Playbook{
+			Playbook:   p.Playbook,
+			Host:       p.Host,
+			Groups:     p.Groups,
+			ExtraVars:  p.ExtraVars,
+			Replayable: p.Replayable,
+			Metadata:   p.Metadata,
+			Sensitive:  p.Sensitive,
+		})
+	}
+}
+
+// internal returns the form of inv the internal packages work on. Both
+// share their maps and slices; maps inv leaves nil are created, as the
+// internal packages expect them.
+func (inv *Inventory) internal() *inventory.Inventory {
+	out := inventory.New()
+	if inv.Vars != nil {
+		out.Vars = inv.Vars
+	}
+	out.Sensitive = inv.Sensitive
+	for name, h := range inv.Hosts {
+		out.Hosts[name] = &inventory.Host{
+			Name:      h.Name,
+			Variables: orEmpty(h.Variables),
+			Groups:    h.Groups,
+			Enabled:   h.Enabled,
+			Metadata:  orEmpty(h.Metadata),
+			Sensitive: h.Sensitive,
+		}
+	}
+	for name, g := range inv.Groups {
+		out.Groups[name] = &inventory.Group{
+			Name:      g.Name,
+			Variables: orEmpty(g.Variables),
+			Children:  g.Children,
+			Hosts:     g.Hosts,
+			Parents:   g.Parents,
+			Declared:  g.declared,
+			Sensitive: g.Sensitive,
+		}
+	}
+	for _, p := range inv.Playbooks {
+		out.Playbooks = append(out.Playbooks, &inventory.Playbook{
+			Playbook:   p.Playbook,
+			Host:       p.Host,
+			Groups:     p.Groups,
+			ExtraVars:  p.ExtraVars,
+			Replayable: p.Replayable,
+			Metadata:   p.Metadata,
+			Sensitive:  p.Sensitive,
+		})
+	}
+	return out
+}
+
+func orEmpty[T any](m map[string]T) map[string]T {
+	if m == nil {
+		return make(map[string]T)
+	}
+	return m
+}
@@ -0,0 +1,45 @@
+package tfinventory
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInventoryRoundTrip(t *testing.T) {
+	inv, err := Parse(context.Background(), strings.NewReader(`{"values": {"root_module": {"resources": [
+  {"type": "ansible_group", "values": {"name": "web", "children": ["edge"]}},
+  {"type": "ansible_host", "values": {"name": "web1", "groups": ["web", "adhoc"], "variables": {"password": "pw"}}, "sensitive_values": {"variables": {"password": true}}}
+]}}}`), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := inv.Groups["web"]; g == nil || !g.declared || inv.Groups["adhoc"].declared {
+		t.Fatalf("declared groups not kept: %#v", inv.Groups)
+	}
+	if got := Filter(inv, nil, nil); !got.Groups["web"].declared || got.Groups["edge"].Parents[0] != "web" {
+		t.Fatalf("groups changed by a copy: %#v", got.Groups)
+	}
+
+	web1 := inv.Hosts["web1"]
+	Redact(inv, MaskSensitive)
+	if inv.Hosts["web1"] != web1 || web1.Variables["password"] != "***" {
+		t.Fatalf("Redact should update the hosts in place: %#v", web1)
+	}
+}
+
+func TestInventoryBuiltByHand(t *testing.T) {
+	inv := &Inventory{Hosts: map[string]*Host{"db1": {Name: "db1", Enabled: true}}}
+	extra := &Inventory{Hosts: map[string]*Host{"db1": {Name: "db1", Variables: map[string]any{"os": "bsd"}, Enabled: true}}}
+	if err := Merge(inv, extra, LastWins); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, inv, "ini"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "db1 os=bsd") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
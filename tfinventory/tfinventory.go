@@ -0,0 +1,310 @@
+package tfinventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/expr"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/iohandler"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/parser"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/vault"
+)
+
+// Rule builds hosts from resources other than the ansible/ansible provider's,
+// such as aws_instance or hcloud_server.
+type Rule = parser.Rule
+
+// AddressMapping describes how the connection address of a host is derived
+// from its variables.
+type AddressMapping = inventory.AddressMapping
+
+// AddressFamily selects which kind of IP address is preferred.
+type AddressFamily = inventory.AddressFamily
+
+// Address families.
+const (
+	AnyFamily = inventory.AnyFamily
+	IPv4      = inventory.IPv4
+	IPv6      = inventory.IPv6
+)
+
+// ConflictPolicy decides which value Merge keeps when both inventories
+// define a variable.
+type ConflictPolicy = inventory.ConflictPolicy
+
+// Conflict policies.
+const (
+	LastWins       = inventory.LastWins
+	FirstWins      = inventory.FirstWins
+	FailOnConflict = inventory.FailOnConflict
+)
+
+// SensitiveMode decides how Redact treats variables Terraform marked as
+// sensitive.
+type SensitiveMode = inventory.SensitiveMode
+
+// Sensitive modes.
+const (
+	MaskSensitive = inventory.MaskSensitive
+	OmitSensitive = inventory.OmitSensitive
+	KeepSensitive = inventory.KeepSensitive
+)
+
+// DefaultAddressMapping maps the ip variable to ansible_host without its
+// CIDR suffix, as the command does without configuration.
+func DefaultAddressMapping() AddressMapping {
+	return inventory.DefaultAddressMapping()
+}
+
+// Options adjusts how Parse builds the inventory. The zero value behaves
+// like the command without a config file.
+type Options struct {
+	// Source names the input, e.g. a file name. It is recorded as the
+	// "source" entry of Host.Metadata.
+	Source string
+	// Rules build hosts from other resource types. The first rule listing a
+	// resource's type is used.
+	Rules []Rule
+	// AddressMapping derives ansible_host; nil means DefaultAddressMapping.
+	AddressMapping *AddressMapping
+}
+
+// ErrUnknownFormat is returned by Encode and Decode for formats not listed
+// by Formats.
+var ErrUnknownFormat = errors.New("tfinventory: unknown format")
+
+// Parse reads a Terraform state from r and returns its inventory with the
+// address mapping of opts applied. Values Terraform marked as sensitive are
+// returned in clear text and flagged in the Sensitive fields; call Redact
+// before writing them anywhere. Parsing stops with the context's error once
+// ctx is done.
+func Parse(ctx context.Context, r io.Reader, opts Options) (*Inventory, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	inv, err := parser.ParseInventoryReaderWithOptions(&contextReader{ctx: ctx, r: r}, parser.Options{
+		Source: opts.Source,
+		Rules:  opts.Rules,
+	})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	mapping := DefaultAddressMapping()
+	if opts.AddressMapping != nil {
+		mapping = *opts.AddressMapping
+	}
+	inv.MapAddresses(mapping)
+	return exportInventory(inv), nil
+}
+
+// contextReader fails reads once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// Filter returns a copy of inv with the named hosts and the hosts of the
+// named groups, like the --host and --group flags. Empty lists select
+// everything.
+func Filter(inv *Inventory, hosts, groups []string) *Inventory {
+	return exportInventory(inv.internal().CopyFiltered(hosts, groups))
+}
+
+// Limit returns a copy of inv with the hosts matching an Ansible host
+// pattern such as "web:&prod:!db*", like ansible-playbook --limit.
+func Limit(inv *Inventory, pattern string) (*Inventory, error) {
+	out, err := inv.internal().Limit(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return exportInventory(out), nil
+}
+
+// Where returns a copy of inv with the hosts whose variables satisfy the
+// expression, e.g. `os == "linux" && region in ["eu-1"]`, like the --where
+// flag.
+func Where(inv *Inventory, expression string) (*Inventory, error) {
+	e, err := expr.Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+	out, err := inv.internal().Where(e.Match)
+	if err != nil {
+		return nil, err
+	}
+	return exportInventory(out), nil
+}
+
+// Merge adds the hosts, groups, variables and playbook runs of src to dst,
+// like repeated --input flags. Variables defined in both with different
+// values are resolved by policy; FailOnConflict returns an error naming the
+// variable; dst may be partly merged then.
+func Merge(dst, src *Inventory, policy ConflictPolicy) error {
+	in := dst.internal()
+	err := in.Merge(src.internal(), policy)
+	dst.set(in)
+	return err
+}
+
+// Redact masks or removes the variables and playbook extra vars flagged as
+// sensitive, like the --sensitive flag. KeepSensitive leaves them alone.
+func Redact(inv *Inventory, mode SensitiveMode) {
+	in := inv.internal()
+	in.Redact(mode, nil)
+	inv.set(in)
+}
+
+// VaultValue is a variable encrypted with Ansible Vault, as Decode returns
+// it. Ciphertext holds the "$ANSIBLE_VAULT;1.1;AES256" text. Encode writes it
+// as an inline "!vault" value in YAML and as {"__ansible_vault": "..."} in
+// JSON; INI cannot hold it, see ErrVaultINI.
+type VaultValue struct {
+	Ciphertext string
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (v VaultValue) MarshalYAML() (interface{}, error) {
+	return vault.Value{Ciphertext: v.Ciphertext}.MarshalYAML()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (v VaultValue) MarshalJSON() ([]byte, error) {
+	return vault.Value{Ciphertext: v.Ciphertext}.MarshalJSON()
+}
+
+// formats lists the names accepted by Encode and Decode.
+var formats = []string{"yaml", "ini", "json", "ansible"}
+
+// Formats returns the names of the inventory formats: yaml, ini, json (the
+// inventory model itself) and ansible (ansible-inventory --list output).
+func Formats() []string {
+	return slices.Clone(formats)
+}
+
+// ErrVaultINI is returned by Encode for INI output of an inventory holding
+// a VaultValue, which INI cannot represent.
+var ErrVaultINI = errors.New("tfinventory: ini cannot hold vault-encrypted values")
+
+// Encode writes inv to w in the named format.
+func Encode(w io.Writer, inv *Inventory, format string) error {
+	if !slices.Contains(formats, format) {
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+	in := inv.internal()
+	if format == "ini" {
+		if name := vaultVariable(in); name != "" {
+			return fmt.Errorf("%w: %s", ErrVaultINI, name)
+		}
+	}
+	return iohandler.OutputInventory(w, in, format)
+}
+
+// vaultVariable names a variable written to INI that holds a VaultValue at
+// any depth, or returns "" when there is none.
+func vaultVariable(inv *inventory.Inventory) string {
+	find := func(owner string, vars map[string]any) string {
+		for _, k := range slices.Sorted(maps.Keys(vars)) {
+			if holdsVaultValue(vars[k]) {
+				return owner + k
+			}
+		}
+		return ""
+	}
+	if name := find("", inv.Vars); name != "" {
+		return name
+	}
+	for _, g := range slices.Sorted(maps.Keys(inv.Groups)) {
+		if name := find("group "+g+": ", inv.Groups[g].Variables); name != "" {
+			return name
+		}
+	}
+	for _, h := range slices.Sorted(maps.Keys(inv.Hosts)) {
+		if name := find("host "+h+": ", inv.Hosts[h].Variables); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+func holdsVaultValue(v any) bool {
+	switch x := v.(type) {
+	case VaultValue, vault.Value:
+		return true
+	case map[string]any:
+		for _, e := range x {
+			if holdsVaultValue(e) {
+				return true
+			}
+		}
+	case []any:
+		for _, e := range x {
+			if holdsVaultValue(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Decode reads an inventory in the named format, such as one written by
+// Encode, so that encoding and decoding round-trips. INI values are read as
+// the JSON Encode writes, not with Ansible's rules for hand-written files.
+// Numbers are read as float64; vault-encrypted values are read as
+// VaultValue, stay encrypted and are written back unchanged by Encode.
+func Decode(r io.Reader, format string) (*Inventory, error) {
+	if !slices.Contains(formats, format) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+	inv, err := iohandler.ReadInventory(r, format)
+	if err != nil {
+		return nil, err
+	}
+	exportVaultValues(inv.Vars)
+	for _, g := range inv.Groups {
+		exportVaultValues(g.Variables)
+	}
+	for _, h := range inv.Hosts {
+		exportVaultValues(h.Variables)
+	}
+	for _, p := range inv.Playbooks {
+		exportVaultValues(p.ExtraVars)
+	}
+	return exportInventory(inv), nil
+}
+
+// exportVaultValues replaces the internal vault.Value in vars, at any depth,
+// with VaultValue.
+func exportVaultValues(vars map[string]any) {
+	for k, v := range vars {
+		vars[k] = exportVaultValue(v)
+	}
+}
+
+func exportVaultValue(v any) any {
+	switch x := v.(type) {
+	case vault.Value:
+		return VaultValue{Ciphertext: x.Ciphertext}
+	case map[string]any:
+		exportVaultValues(x)
+	case []any:
+		for i := range x {
+			x[i] = exportVaultValue(x[i])
+		}
+	}
+	return v
+}
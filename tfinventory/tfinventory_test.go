@@ -0,0 +1,147 @@
+package tfinventory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testState = `{"values": {"root_module": {"resources": [
+  {"type": "ansible_host", "values": {"name": "web1", "groups": ["web"], "variables": {"addr": "fd00::1", "ip": "10.0.0.1/24"}}},
+  {"type": "aws_instance", "values": {"tags": {"Name": "app1"}, "private_ip": "10.0.1.5"}}
+]}}}`
+
+func TestParseOptions(t *testing.T) {
+	inv, err := Parse(context.Background(), strings.NewReader(testState), Options{
+		Source:         "prod.tfstate",
+		Rules:          []Rule{{Types: []string{"aws_instance"}, Name: "values.tags.Name", Vars: map[string]string{"ansible_host": "values.private_ip"}}},
+		AddressMapping: &AddressMapping{Sources: []string{"addr"}, Target: "ansible_host"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	web1 := inv.Hosts["web1"]
+	if web1.Variables["ansible_host"] != "fd00::1" || web1.Variables["ip"] != "10.0.0.1/24" {
+		t.Fatalf("address mapping not applied: %v", web1.Variables)
+	}
+	if web1.Metadata["source"] != "prod.tfstate" {
+		t.Fatalf("unexpected metadata: %v", web1.Metadata)
+	}
+	if app := inv.Hosts["app1"]; app == nil || app.Variables["ansible_host"] != "10.0.1.5" {
+		t.Fatalf("rule not applied: %#v", inv.Hosts)
+	}
+}
+
+func TestParseCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Parse(ctx, strings.NewReader(testState), Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	inv, err := Parse(context.Background(), strings.NewReader(testState), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.Hosts["web1"].Enabled = true
+	for _, format := range Formats() {
+		var buf bytes.Buffer
+		if err := Encode(&buf, inv, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		got, err := Decode(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if got.Hosts["web1"] == nil || got.Hosts["web1"].Variables["ansible_host"] != "10.0.0.1" {
+			t.Errorf("%s: unexpected round trip: %#v", format, got.Hosts)
+		}
+	}
+
+	if err := Encode(&bytes.Buffer{}, inv, "toml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, got %v", err)
+	}
+	if _, err := Decode(strings.NewReader(""), "toml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, got %v", err)
+	}
+	Formats()[0] = "changed"
+	if Formats()[0] != "yaml" {
+		t.Fatal("Formats must return a copy")
+	}
+}
+
+func TestDecodeVaultValue(t *testing.T) {
+	in := "all:\n  hosts:\n    db1:\n      db_password: !vault |\n        $ANSIBLE_VAULT;1.1;AES256\n        3132\n      tokens:\n        - !vault |\n          $ANSIBLE_VAULT;1.1;AES256\n          3334\n"
+	inv, err := Decode(strings.NewReader(in), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := inv.Hosts["db1"].Variables
+	v, ok := vars["db_password"].(VaultValue)
+	if !ok || !strings.HasPrefix(v.Ciphertext, "$ANSIBLE_VAULT;1.1;AES256") {
+		t.Fatalf("expected VaultValue, got %#v", vars["db_password"])
+	}
+	if _, ok := vars["tokens"].([]any)[0].(VaultValue); !ok {
+		t.Fatalf("nested vault values should be VaultValue: %#v", vars["tokens"])
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, inv, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"__ansible_vault": "$ANSIBLE_VAULT;1.1;AES256\n3132`) {
+		t.Fatalf("vault value not encoded: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := Encode(&buf, inv, "ini"); !errors.Is(err, ErrVaultINI) || !strings.Contains(err.Error(), "host db1: db_password") {
+		t.Fatalf("expected ErrVaultINI naming the variable, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("nothing should be written: %q", buf.String())
+	}
+}
+
+func TestMergeRedact(t *testing.T) {
+	a, err := Parse(context.Background(), strings.NewReader(testState), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Parse(context.Background(), strings.NewReader(`{"values": {"root_module": {"resources": [
+  {"type": "ansible_host", "values": {"name": "web1", "variables": {"ip": "10.0.0.9"}}},
+  {"type": "ansible_host", "values": {"name": "db1", "variables": {"password": "pw"}}, "sensitive_values": {"variables": {"password": true}}}
+]}}}`), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Merge(Filter(a, nil, nil), b, FailOnConflict); err == nil {
+		t.Fatal("expected conflict error")
+	}
+	if err := Merge(a, b, FirstWins); err != nil {
+		t.Fatal(err)
+	}
+	if a.Hosts["web1"].Variables["ansible_host"] != "10.0.0.1" || a.Hosts["db1"] == nil {
+		t.Fatalf("unexpected merge result: %#v", a.Hosts)
+	}
+	Redact(a, MaskSensitive)
+	if a.Hosts["db1"].Variables["password"] != "***" {
+		t.Fatalf("sensitive value not masked: %v", a.Hosts["db1"].Variables)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	inv, err := Parse(context.Background(), strings.NewReader(testState), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Filter(inv, nil, []string{"web"}); len(got.Hosts) != 1 || got.Hosts["web1"] == nil {
+		t.Fatalf("unexpected filter result: %#v", got.Hosts)
+	}
+	if _, err := Where(inv, "os =="); err == nil {
+		t.Fatal("expected error for invalid expression")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/iohandler"
+	"github.com/urfave/cli/v2"
+)
+
+// validateCommand reports structural problems of the inventory and exits
+// non-zero when any error (or, with --strict, any warning) is found.
+func validateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "validate",
+		Usage: "Check the inventory for structural problems",
+		Flags: append(inputFlags(),
+			&cli.StringFlag{
+				Name:    "format",
+				Aliases: []string{"f"},
+				Value:   "text",
+				Usage:   "Findings format: text or json",
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "Treat warnings as errors",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			inv, cfg, err := loadFromContext(c)
+			if err != nil {
+				return err
+			}
+			mapping, err := cfg.Address.AddressMapping()
+			if err != nil {
+				return err
+			}
+			findings := inv.Validate(mapping.Target)
+			valid := true
+			for _, f := range findings {
+				if f.Severity == inventory.SeverityError || c.Bool("strict") {
+					valid = false
+				}
+			}
+			if err := iohandler.OutputFindings(os.Stdout, findings, valid, strings.ToLower(c.String("format"))); err != nil {
+				return err
+			}
+			if !valid {
+				return cli.Exit("", 1)
+			}
+			return nil
+		},
+	}
+}
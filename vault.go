@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/inventory"
+	"github.com/HilkopterBob/terraform-ansible-inventory/internal/vault"
+	"github.com/urfave/cli/v2"
+)
+
+// vaultFlags returns the flags selecting variables for Ansible Vault
+// encryption.
+func vaultFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "vault-sensitive",
+			Usage: "Encrypt the variables Terraform marked as sensitive with Ansible Vault",
+		},
+		&cli.StringFlag{
+			Name:  "vault-pattern",
+			Usage: "Encrypt the variables whose name matches this regular expression, e.g. 'password|token'",
+		},
+		&cli.StringFlag{
+			Name:    "vault-password-file",
+			EnvVars: []string{"ANSIBLE_VAULT_PASSWORD_FILE"},
+			Usage:   "File holding the vault password; defaults to $" + vaultPasswordEnv,
+		},
+	}
+}
+
+// sensitiveFlag returns the --sensitive flag choosing how values Terraform
+// marked as sensitive are written.
+func sensitiveFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "sensitive",
+		Value: "mask",
+		Usage: "How to write variables Terraform marked as sensitive: mask (as \"" + inventory.Mask + "\"), omit, or keep",
+	}
+}
+
+// encrypterFromContext returns the encrypter configured by the vault flags,
+// or nil when no variable is selected for encryption.
+func encrypterFromContext(c *cli.Context) (*vault.Encrypter, error) {
+	if !c.Bool("vault-sensitive") && c.String("vault-pattern") == "" {
+		return nil, nil
+	}
+	enc := &vault.Encrypter{Sensitive: c.Bool("vault-sensitive")}
+	if p := c.String("vault-pattern"); p != "" {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --vault-pattern: %w", err)
+		}
+		enc.Pattern = re
+	}
+
+	if path := c.String("vault-password-file"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault password: %w", err)
+		}
+		enc.Password = bytes.TrimSpace(data)
+	} else {
+		enc.Password = []byte(os.Getenv(vaultPasswordEnv))
+	}
+	if len(enc.Password) == 0 {
+		return nil, errors.New("vault encryption needs a password: set --vault-password-file or $" + vaultPasswordEnv)
+	}
+	return enc, nil
+}
+
+// redact applies the --sensitive mode to inv. Sensitive variables enc is
+// going to encrypt are left alone, as they will not be written in clear
+// text.
+func redact(inv *inventory.Inventory, mode inventory.SensitiveMode, enc *vault.Encrypter) {
+	var exempt func(string) bool
+	if enc != nil {
+		exempt = func(name string) bool { return enc.Selects(name, true) }
+	}
+	inv.Redact(mode, exempt)
+}
+
+// encryptsOutput reports whether enc selects any variable printed with the
+// inventory: inventory and group variables and, when hosts is set, host
+// variables.
+func encryptsOutput(enc *vault.Encrypter, inv *inventory.Inventory, hosts bool) bool {
+	selects := func(vars map[string]any, sensitive map[string]bool) bool {
+		for k := range vars {
+			if enc.Selects(k, sensitive[k]) {
+				return true
+			}
+		}
+		return false
+	}
+	if selects(inv.Vars, inv.Sensitive) {
+		return true
+	}
+	for _, g := range inv.Groups {
+		if selects(g.Variables, g.Sensitive) {
+			return true
+		}
+	}
+	if hosts {
+		for _, h := range inv.Hosts {
+			if selects(h.Variables, h.Sensitive) {
+				return true
+			}
+		}
+	}
+	return false
+}